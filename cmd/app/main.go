@@ -46,11 +46,39 @@ func main() {
 	// Создаем репозиторий
 	repo := repository.NewOrderRepository(dataBase)
 
+	// Политика для заказов, UID которых уже сохранён с другим содержимым
+	conflictPolicy, err := repository.ParseConflictPolicy(cfg.Ingest.ConflictPolicy)
+	if err != nil {
+		log.Fatalf("Invalid ingest config: %v", err)
+	}
+
 	// Создаем сервис
-	orderService := service.NewOrderService(repo, 0)
+	orderService := service.NewOrderService(repo, &cfg.Cache, conflictPolicy)
+
+	// Инициализируем Kafka консьюмер
+	consumer, err := kafka.NewConsumer(&cfg.Kafka, orderService)
+	if err != nil {
+		log.Fatalf("Failed to create Kafka consumer: %v", err)
+	}
+
+	// Публикация событий о сохранённых заказах из outbox
+	if cfg.Outbox.Topic == cfg.Kafka.Topic {
+		log.Fatalf("Outbox topic must differ from the consumed topic %s", cfg.Kafka.Topic)
+	}
+	eventProducer, err := kafka.NewProducer(&cfg.Kafka)
+	if err != nil {
+		log.Fatalf("Failed to create Kafka producer for outbox: %v", err)
+	}
+	outboxRelay, err := kafka.NewOutboxRelay(&cfg.Outbox, repo, eventProducer)
+	if err != nil {
+		log.Fatalf("Failed to create outbox relay: %v", err)
+	}
 
 	// Инициализируем HTTP хэндлер
-	httpHandler := httptransport.NewHandler(orderService)
+	if cfg.Server.AdminToken == "" {
+		log.Println("ADMIN_TOKEN is not set: consumer pause and resume endpoints are disabled")
+	}
+	httpHandler := httptransport.NewHandler(orderService, consumer, cfg.Server.AdminToken)
 	router := httpHandler.InitRoutes()
 
 	// Создаем HTTP сервер
@@ -59,17 +87,14 @@ func main() {
 		Handler: router,
 	}
 
-	// Инициализируем Kafka консьюмер
-	consumer, err := kafka.NewConsumer(&cfg.Kafka, orderService)
-	if err != nil {
-		log.Fatalf("Failed to create Kafka consumer: %v", err)
-	}
-
 	// Запускаем консьюмер
 	if err := consumer.Start(); err != nil {
 		log.Fatalf("Failed to start Kafka consumer: %v", err)
 	}
 
+	// Запускаем публикацию событий
+	outboxRelay.Start()
+
 	// Запускаем HTTP сервер в отдельной горутине
 	go func() {
 		log.Printf("HTTP server starting on port %s", cfg.Server.Port)
@@ -78,6 +103,15 @@ func main() {
 		}
 	}()
 
+	// Прогреваем кэш в фоне; до завершения /api/v1/ready отвечает "not ready"
+	warmUpCtx, cancelWarmUp := context.WithCancel(context.Background())
+	defer cancelWarmUp()
+	go func() {
+		if err := orderService.RestoreCache(warmUpCtx); err != nil {
+			log.Printf("Cache warm-up failed: %v", err)
+		}
+	}()
+
 	log.Printf("Order service, Kafka consumer and HTTP server initialized successfully")
 
 	// Ожидаем сигнал для graceful shutdown
@@ -87,6 +121,7 @@ func main() {
 	// Блокируемся до получения сигнала
 	<-sigChan
 	log.Println("Received shutdown signal, stopping services...")
+	cancelWarmUp()
 
 	// Graceful shutdown HTTP сервера
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -101,5 +136,11 @@ func main() {
 		log.Printf("Error stopping Kafka consumer: %v", err)
 	}
 
+	// Останавливаем публикацию событий; неопубликованные останутся в outbox
+	outboxRelay.Stop()
+	if err := eventProducer.Close(); err != nil {
+		log.Printf("Error closing outbox producer: %v", err)
+	}
+
 	log.Println("Application shutdown completed")
 }
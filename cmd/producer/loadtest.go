@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// pacerTick как часто темп отправки пересчитывается и выдаются разрешения
+const pacerTick = time.Millisecond
+
+// loadOptions параметры нагрузочного теста
+type loadOptions struct {
+	rate        float64       // целевая скорость, заказов в секунду; 0 — без ограничения
+	concurrency int           // параллельных отправителей
+	duration    time.Duration // длительность, 0 — без ограничения
+	count       int           // сколько заказов отправить, 0 — без ограничения
+	rampUp      time.Duration // за какое время скорость линейно растёт от 0 до rate
+}
+
+// loadStats результаты отправки, собираемые из callback'ов producer'а
+type loadStats struct {
+	mu        sync.Mutex
+	acked     int
+	failed    int
+	latencies []time.Duration
+}
+
+func (s *loadStats) record(latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.failed++
+		return
+	}
+	s.acked++
+	s.latencies = append(s.latencies, latency)
+}
+
+func (s *loadStats) fail() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed++
+}
+
+// percentile возвращает перцентиль p (0..100) отсортированных задержек
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// report печатает итоги нагрузочного теста
+func (s *loadStats) report(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	seconds := elapsed.Seconds()
+
+	fmt.Printf("Load test finished in %v\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("  sent:       %d\n", s.acked)
+	fmt.Printf("  failed:     %d\n", s.failed)
+	if seconds > 0 {
+		fmt.Printf("  throughput: %.1f msg/s\n", float64(s.acked)/seconds)
+	}
+	if len(s.latencies) > 0 {
+		fmt.Printf("  ack latency: p50=%v p90=%v p95=%v p99=%v max=%v\n",
+			percentile(s.latencies, 50),
+			percentile(s.latencies, 90),
+			percentile(s.latencies, 95),
+			percentile(s.latencies, 99),
+			s.latencies[len(s.latencies)-1],
+		)
+	}
+}
+
+// targetSent сколько заказов должно быть отправлено к моменту elapsed:
+// на разгоне скорость растёт линейно, затем постоянна
+func (o loadOptions) targetSent(elapsed time.Duration) float64 {
+	t := elapsed.Seconds()
+	if o.rampUp <= 0 {
+		return o.rate * t
+	}
+	ramp := o.rampUp.Seconds()
+	if t < ramp {
+		return o.rate * t * t / (2 * ramp)
+	}
+	return o.rate*ramp/2 + o.rate*(t-ramp)
+}
+
+// pace выдаёт разрешения на отправку с заданной скоростью, пока не
+// достигнуты count или duration либо не отменён ctx. Если отправители не
+// успевают, разрешения не копятся сверх буфера канала.
+func (o loadOptions) pace(ctx context.Context, permits chan<- struct{}) {
+	defer close(permits)
+
+	start := time.Now()
+	ticker := time.NewTicker(pacerTick)
+	defer ticker.Stop()
+
+	issued := 0
+	for {
+		if o.count > 0 && issued >= o.count {
+			return
+		}
+		elapsed := time.Since(start)
+		if o.duration > 0 && elapsed >= o.duration {
+			return
+		}
+
+		// Без ограничения скорости разрешения выдаются по мере освобождения
+		// отправителей
+		due := math.MaxInt
+		if o.rate > 0 {
+			due = int(o.targetSent(elapsed))
+		}
+
+		if issued < due {
+			select {
+			case permits <- struct{}{}:
+				issued++
+			case <-ticker.C:
+				// Отправители не успевают: перепроверяем ограничения
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runLoadTest отправляет сгенерированные заказы с заданной скоростью и
+// параллельностью и печатает число отправленных и неудачных сообщений и
+// перцентили времени подтверждения брокером
+func (p *Producer) runLoadTest(ctx context.Context, opts loadOptions) error {
+	if opts.concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive, got %d", opts.concurrency)
+	}
+	if opts.rate < 0 {
+		return fmt.Errorf("rate must not be negative, got %v", opts.rate)
+	}
+
+	stats := &loadStats{}
+	p.onAck = stats.record
+	if p.producer != nil {
+		p.producer.SetQuiet(true)
+	}
+
+	log.Printf("Starting load test: rate %v/s, concurrency %d, duration %v, count %d, ramp-up %v",
+		opts.rate, opts.concurrency, opts.duration, opts.count, opts.rampUp)
+
+	permits := make(chan struct{}, opts.concurrency)
+	start := time.Now()
+	go opts.pace(ctx, permits)
+
+	var wg sync.WaitGroup
+	for range opts.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range permits {
+				order := generateOrder()
+				if p.async != nil {
+					// Ошибки доставки учтутся в callback'е, здесь — только постановки в очередь
+					if err := p.async.SendOrder(ctx, p.config.Topic, order); err != nil && ctx.Err() == nil {
+						stats.fail()
+					}
+					continue
+				}
+				// В синхронном режиме результат учитывается через onAck
+				p.sendOrder(order)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// В асинхронном режиме дожидаемся подтверждения всех сообщений из очереди
+	if p.async != nil {
+		if err := p.async.Flush(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	stats.report(time.Since(start))
+	return nil
+}
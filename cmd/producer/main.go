@@ -4,7 +4,8 @@ import (
 	"Order-tracker-service/config"
 	"Order-tracker-service/internal/domain"
 	"Order-tracker-service/internal/transport/kafka"
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
@@ -20,21 +21,59 @@ import (
 
 // Producer представляет Kafka producer
 type Producer struct {
-	producer *kafka.Producer
+	producer *kafka.Producer      // синхронный режим
+	async    *kafka.AsyncProducer // асинхронный режим (PRODUCER_MODE=async)
 	config   *config.KafkaConfig
+
+	// onAck, если задан, получает время до подтверждения брокером и ошибку
+	// каждой отправки вместо записи в лог. Задаётся до начала отправки.
+	onAck func(latency time.Duration, err error)
 }
 
 // NewProducer создает новый экземпляр producer
-func NewProducer(cfg *config.KafkaConfig) (*Producer, error) {
+func NewProducer(cfg *config.KafkaConfig, async bool) (*Producer, error) {
+	p := &Producer{config: cfg}
+
+	if async {
+		prod, err := kafka.NewAsyncProducer(cfg, kafka.AsyncCallbacks{
+			OnSuccess: func(d kafka.Delivery) {
+				if p.onAck != nil {
+					p.onAck(d.Latency, nil)
+					return
+				}
+				log.Printf("Order sent successfully: %s (partition %d, offset %d, latency %v)",
+					d.Message.Key, d.Partition, d.Offset, d.Latency)
+			},
+			OnError: func(d kafka.Delivery, err error) {
+				if p.onAck != nil {
+					p.onAck(d.Latency, err)
+					return
+				}
+				log.Printf("Failed to send order %s: %v", d.Message.Key, err)
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create producer: %w", err)
+		}
+		p.async = prod
+		return p, nil
+	}
+
 	prod, err := kafka.NewProducer(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create producer: %w", err)
 	}
+	p.producer = prod
+	return p, nil
+}
 
-	return &Producer{
-		producer: prod,
-		config:   cfg,
-	}, nil
+// Close закрывает producer; в асинхронном режиме дожидается отправки
+// всех заказов из очереди
+func (p *Producer) Close() error {
+	if p.async != nil {
+		return p.async.Close()
+	}
+	return p.producer.Close()
 }
 
 // generateOrder генерирует заказ с уникальным ID
@@ -127,17 +166,23 @@ func generateOrder() *domain.Order {
 	}
 }
 
-// sendOrder отправляет заказ в Kafka
+// sendOrder отправляет заказ в Kafka с ключом order_uid. В асинхронном
+// режиме заказ только ставится в очередь, результат приходит в callback'и.
 func (p *Producer) sendOrder(order *domain.Order) error {
-	// Сериализуем заказ в JSON
-	orderJSON, err := json.Marshal(order)
-	if err != nil {
-		return fmt.Errorf("failed to marshal order: %w", err)
+	if p.async != nil {
+		if err := p.async.SendOrder(context.Background(), p.config.Topic, order); err != nil {
+			return fmt.Errorf("failed to queue order: %w", err)
+		}
+		return nil
 	}
 
-	// Отправляем сообщение в Kafka
-	if err := p.producer.SendMessage(p.config.Topic, orderJSON); err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+	start := time.Now()
+	err := p.producer.SendOrder(p.config.Topic, order)
+	if p.onAck != nil {
+		p.onAck(time.Since(start), err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send order: %w", err)
 	}
 
 	return nil
@@ -155,7 +200,7 @@ func (p *Producer) startGeneration(interval time.Duration) {
 
 		if err := p.sendOrder(order); err != nil {
 			log.Printf("Failed to send order %s: %v", order.OrderUID, err)
-		} else {
+		} else if p.async == nil {
 			log.Printf("Order sent successfully: %s (Customer: %s, Amount: %d RUB)",
 				order.OrderUID, order.CustomerID, order.Payment.Amount)
 		}
@@ -166,6 +211,20 @@ func main() {
 	// Инициализируем генератор случайных чисел
 	rand.Seed(time.Now().UnixNano())
 
+	// Режим отправки заказов из файла вместо генерации случайных
+	replayPath := flag.String("file", "", "replay orders from a JSON or JSONL file (\"-\" for stdin) instead of generating them")
+	rate := flag.Float64("rate", 0, "orders per second in file replay and load test modes, 0 for no limit")
+	rewriteUID := flag.Bool("rewrite-uid", false, "replace order_uid, payment.transaction and payment.request_id with new UUIDs so a fixture can be replayed many times")
+	rewriteDate := flag.Bool("rewrite-date", false, "replace date_created with the current time")
+
+	// Нагрузочный тест: отправка сгенерированных заказов с отчётом о скорости и задержках
+	load := flag.Bool("load", false, "run a load test and report throughput and ack latency")
+	concurrency := flag.Int("concurrency", 4, "concurrent senders in load test mode")
+	duration := flag.Duration("duration", 0, "load test duration, 0 for no limit")
+	count := flag.Int("count", 0, "orders to send in load test mode, 0 for no limit")
+	rampUp := flag.Duration("ramp-up", 0, "time to ramp the rate up linearly from zero in load test mode")
+	flag.Parse()
+
 	// Загружаем переменные окружения (локально .env, в контейнере переменные уже установлены)
 	_ = godotenv.Load()
 
@@ -175,8 +234,11 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Режим отправки: sync (по умолчанию) или async
+	async := os.Getenv("PRODUCER_MODE") == "async"
+
 	// Создаем producer
-	producer, err := NewProducer(&cfg.Kafka)
+	producer, err := NewProducer(&cfg.Kafka, async)
 	if err != nil {
 		log.Fatalf("Failed to create producer: %v", err)
 	}
@@ -193,21 +255,64 @@ func main() {
 	log.Printf("Kafka Producer Configuration:")
 	log.Printf("   Brokers: %v", cfg.Kafka.Brokers)
 	log.Printf("   Topic: %s", cfg.Kafka.Topic)
+	log.Printf("   Partitioner: %s", cfg.Kafka.Partitioner)
+	log.Printf("   Async: %v", async)
 	log.Printf("   Generation Interval: %v", interval)
 
 	// Обработка сигналов для graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Запускаем генерацию в отдельной горутине
-	go producer.startGeneration(interval)
+	if *load {
+		// Нагрузочный тест до достижения -count/-duration или сигнала
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-sigChan
+			log.Println("Received shutdown signal, stopping load test...")
+			cancel()
+		}()
+
+		err := producer.runLoadTest(ctx, loadOptions{
+			rate:        *rate,
+			concurrency: *concurrency,
+			duration:    *duration,
+			count:       *count,
+			rampUp:      *rampUp,
+		})
+		cancel()
+		if err != nil {
+			log.Printf("Load test failed: %v", err)
+		}
+	} else if *replayPath != "" {
+		// Отправляем заказы из файла; сигнал прерывает отправку
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-sigChan
+			log.Println("Received shutdown signal, stopping replay...")
+			cancel()
+		}()
+
+		err := producer.replayFile(ctx, replayOptions{
+			path:        *replayPath,
+			rate:        *rate,
+			rewriteUID:  *rewriteUID,
+			rewriteDate: *rewriteDate,
+		})
+		cancel()
+		if err != nil {
+			log.Printf("Replay failed: %v", err)
+		}
+	} else {
+		// Запускаем генерацию в отдельной горутине
+		go producer.startGeneration(interval)
 
-	// Ожидаем сигнал завершения
-	<-sigChan
-	log.Println("Received shutdown signal, stopping producer...")
+		// Ожидаем сигнал завершения
+		<-sigChan
+		log.Println("Received shutdown signal, stopping producer...")
+	}
 
 	// Закрываем producer
-	if err := producer.producer.Close(); err != nil {
+	if err := producer.Close(); err != nil {
 		log.Printf("Error closing producer: %v", err)
 	}
 
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"Order-tracker-service/internal/domain"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// replayOptions параметры отправки заказов из файла
+type replayOptions struct {
+	path        string  // путь к файлу, "-" — stdin
+	rate        float64 // заказов в секунду, 0 — без ограничения
+	rewriteUID  bool    // заменять order_uid, payment.transaction и payment.request_id на новые UUID
+	rewriteDate bool    // заменять date_created на текущее время
+}
+
+// openReplaySource открывает файл с заказами или stdin
+func openReplaySource(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// readOrders читает заказы из JSON (объект или массив объектов) или JSONL
+// и передаёт их в fn по одному, не загружая файл целиком
+func readOrders(r io.Reader, fn func(order *domain.Order) error) error {
+	reader := bufio.NewReader(r)
+
+	// Массив определяем по первому непробельному символу
+	var first byte
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			first = b
+			reader.UnreadByte()
+			break
+		}
+	}
+
+	decoder := json.NewDecoder(reader)
+	if first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+	}
+
+	for n := 1; ; n++ {
+		if first == '[' && !decoder.More() {
+			return nil
+		}
+
+		var order domain.Order
+		err := decoder.Decode(&order)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode order #%d: %w", n, err)
+		}
+		if err := fn(&order); err != nil {
+			return err
+		}
+	}
+}
+
+// replayFile отправляет заказы из файла с заданной скоростью
+func (p *Producer) replayFile(ctx context.Context, opts replayOptions) error {
+	src, err := openReplaySource(opts.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var tick <-chan time.Time
+	if opts.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	log.Printf("Replaying orders from %s (rate: %v/s, rewrite uid: %v, rewrite date: %v)",
+		opts.path, opts.rate, opts.rewriteUID, opts.rewriteDate)
+
+	sent, failed := 0, 0
+	errStop := errors.New("replay stopped")
+	err = readOrders(src, func(order *domain.Order) error {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return errStop
+			}
+		} else if ctx.Err() != nil {
+			return errStop
+		}
+
+		// payment.transaction уникален в БД так же, как order_uid: без его
+		// замены повторно отправленный заказ отвергается при сохранении
+		if opts.rewriteUID {
+			order.OrderUID = uuid.New().String()
+			order.Payment.Transaction = uuid.New().String()
+			order.Payment.RequestID = uuid.New().String()
+		}
+		if opts.rewriteDate {
+			order.DateCreated = time.Now()
+		}
+
+		if err := p.sendOrder(order); err != nil {
+			failed++
+			log.Printf("Failed to send order %s: %v", order.OrderUID, err)
+			return nil
+		}
+		sent++
+		return nil
+	})
+
+	log.Printf("Replay finished: %d order(s) sent, %d failed", sent, failed)
+	if errors.Is(err, errStop) {
+		return nil
+	}
+	return err
+}
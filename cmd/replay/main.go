@@ -0,0 +1,86 @@
+package main
+
+import (
+	"Order-tracker-service/config"
+	"Order-tracker-service/internal/transport/kafka"
+	"flag"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Утилита сбрасывает offset'ы группы консьюмеров сервиса на указанный offset
+// или момент времени. При следующем запуске приложения консьюмер заново
+// прочитает сообщения и обработает их через обычный HandleOrder.
+//
+// Пример:
+//
+//	go run cmd/replay/main.go -since 2024-05-01T10:00:00Z -partitions 0,2 -dry-run
+func main() {
+	offset := flag.Int64("offset", -1, "offset to replay from")
+	since := flag.String("since", "", "replay from the first message at or after this time (RFC3339)")
+	partitionsFlag := flag.String("partitions", "", "comma-separated partitions, all by default")
+	group := flag.String("group", "", "consumer group, KAFKA_GROUP_ID by default")
+	dryRun := flag.Bool("dry-run", false, "only report how many messages would be reprocessed")
+	flag.Parse()
+
+	// Загружаем переменные окружения (локально .env, в контейнере переменные уже установлены)
+	_ = godotenv.Load()
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if *group != "" {
+		cfg.Kafka.GroupID = *group
+	}
+
+	spec := kafka.ReplaySpec{Offset: *offset}
+	if *since != "" {
+		if spec.Since, err = time.Parse(time.RFC3339, *since); err != nil {
+			log.Fatalf("Invalid -since: %v", err)
+		}
+	}
+	if *partitionsFlag != "" {
+		for _, p := range strings.Split(*partitionsFlag, ",") {
+			partition, err := strconv.ParseInt(strings.TrimSpace(p), 10, 32)
+			if err != nil {
+				log.Fatalf("Invalid partition %q: %v", p, err)
+			}
+			spec.Partitions = append(spec.Partitions, int32(partition))
+		}
+	}
+
+	replayer, err := kafka.NewReplayer(&cfg.Kafka)
+	if err != nil {
+		log.Fatalf("Failed to connect to Kafka: %v", err)
+	}
+	defer replayer.Close()
+
+	plan, err := replayer.Plan(spec)
+	if err != nil {
+		log.Fatalf("Failed to plan replay: %v", err)
+	}
+
+	var total int64
+	for _, entry := range plan {
+		log.Printf("Partition %d: committed %d, replay from %d to %d, %d messages",
+			entry.Partition, entry.Committed, entry.From, entry.HighWater, entry.Messages)
+		total += entry.Messages
+	}
+	log.Printf("Total messages to reprocess for group %s, topic %s: %d", cfg.Kafka.GroupID, cfg.Kafka.Topic, total)
+
+	if *dryRun {
+		log.Println("Dry run, offsets are not changed")
+		return
+	}
+
+	if err := replayer.Apply(plan); err != nil {
+		log.Fatalf("Failed to reset offsets: %v", err)
+	}
+	log.Println("Offsets reset, start the app to replay messages")
+}
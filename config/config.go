@@ -3,22 +3,85 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
 	Database DatabaseConfig
 	Server   ServerConfig
 	Kafka    KafkaConfig
+	Cache    CacheConfig
+	Ingest   IngestConfig
+	Outbox   OutboxConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Host string
+
+	AdminToken string // токен для паузы и возобновления консьюмера, пусто — они выключены
 }
 
 type KafkaConfig struct {
-	Brokers []string
-	Topic   string
+	Brokers  []string
+	Topic    string
+	DLQTopic string // топик для необработанных сообщений, пусто — не используется
+
+	AvroSchemaFile string // схема Avro для сообщений application/avro, пусто — Avro не принимается
+
+	TLS  KafkaTLSConfig
+	SASL KafkaSASLConfig
+
+	GroupID           string
+	InitialOffset     string   // earliest или latest: откуда читать группе без сохранённых offset'ов
+	RebalanceStrategy []string // range, roundrobin, sticky — в порядке предпочтения
+	SessionTimeout    time.Duration
+	HeartbeatInterval time.Duration
+	RebalanceTimeout  time.Duration
+
+	RetryMax        int           // повторов временной ошибки до отправки в DLQ
+	RetryBackoff    time.Duration // задержка перед первым повтором
+	RetryMaxBackoff time.Duration // максимальная задержка между повторами
+
+	DeliveryGuarantee string        // at-least-once или at-most-once
+	CommitInterval    time.Duration // как часто фиксируются offset'ы обработанных сообщений
+
+	BatchSize   int           // сообщений в пачке, 1 — обработка по одному
+	BatchLinger time.Duration // сколько ждать заполнения пачки
+
+	Workers           int           // параллельных обработчиков на партицию (без пачек)
+	MaxProcessingTime time.Duration // Consumer.MaxProcessingTime в Sarama
+
+	StallTimeout time.Duration // сколько можно не обрабатывать сообщения при отставании, прежде чем считать консьюмер зависшим
+
+	Partitioner string // партиционер producer'а: hash, murmur2 или manual
+
+	ProducerCompression string        // none, gzip, snappy, lz4 или zstd
+	ProducerBatchSize   int           // сообщений в пачке асинхронного producer'а
+	ProducerLinger      time.Duration // сколько асинхронный producer ждёт заполнения пачки
+	ProducerMaxInFlight int           // сколько сообщений может ждать подтверждения, дальше Send блокируется
+}
+
+type KafkaTLSConfig struct {
+	Enabled            bool
+	CAFile             string
+	CertFile           string // клиентский сертификат для mTLS
+	KeyFile            string
+	InsecureSkipVerify bool // не проверять сертификат брокера, только для разработки
+}
+
+type KafkaSASLConfig struct {
+	Mechanism string // PLAIN, SCRAM-SHA-256 или SCRAM-SHA-512; пусто — без SASL
+	Username  string
+	Password  string
+}
+
+type CacheConfig struct {
+	Size     int
+	TTL      time.Duration // срок свежести записи, 0 — бессрочно
+	StaleTTL time.Duration // окно stale-while-revalidate после истечения TTL, 0 — выключено
+
+	NegativeTTL time.Duration // сколько помнить отсутствующие в БД UID, 0 — выключено
 }
 
 func LoadConfig() (*Config, error) {
@@ -38,17 +101,103 @@ func LoadConfig() (*Config, error) {
 	config.Server = ServerConfig{
 		Port: getEnv("SERVER_PORT", "8080"),
 		Host: getEnv("SERVER_HOST", "localhost"),
+
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
 	}
 
 	// Загружаем конфигурацию Kafka
 	config.Kafka = KafkaConfig{
-		Brokers: getEnvAsSlice("KAFKA_BROKERS", []string{"localhost:9092"}),
-		Topic:   getEnv("KAFKA_TOPIC", "orders"),
+		Brokers:  getEnvAsSlice("KAFKA_BROKERS", []string{"localhost:9092"}),
+		Topic:    getEnv("KAFKA_TOPIC", "orders"),
+		DLQTopic: getEnv("KAFKA_DLQ_TOPIC", "orders.dlq"),
+
+		AvroSchemaFile: getEnv("KAFKA_AVRO_SCHEMA_FILE", ""),
+
+		TLS: KafkaTLSConfig{
+			Enabled:            getEnvAsBool("KAFKA_TLS_ENABLED", false),
+			CAFile:             getEnv("KAFKA_TLS_CA_FILE", ""),
+			CertFile:           getEnv("KAFKA_TLS_CERT_FILE", ""),
+			KeyFile:            getEnv("KAFKA_TLS_KEY_FILE", ""),
+			InsecureSkipVerify: getEnvAsBool("KAFKA_TLS_INSECURE_SKIP_VERIFY", false),
+		},
+		SASL: KafkaSASLConfig{
+			Mechanism: getEnv("KAFKA_SASL_MECHANISM", ""),
+			Username:  getEnv("KAFKA_SASL_USERNAME", ""),
+			Password:  getEnv("KAFKA_SASL_PASSWORD", ""),
+		},
+
+		GroupID:           getEnv("KAFKA_GROUP_ID", "order-tracker-group"),
+		InitialOffset:     getEnv("KAFKA_INITIAL_OFFSET", "earliest"),
+		RebalanceStrategy: getEnvAsSlice("KAFKA_REBALANCE_STRATEGY", []string{"roundrobin"}),
+		SessionTimeout:    getEnvAsDuration("KAFKA_SESSION_TIMEOUT", 10*time.Second),
+		HeartbeatInterval: getEnvAsDuration("KAFKA_HEARTBEAT_INTERVAL", 3*time.Second),
+		RebalanceTimeout:  getEnvAsDuration("KAFKA_REBALANCE_TIMEOUT", 60*time.Second),
+
+		RetryMax:        getEnvAsInt("KAFKA_RETRY_MAX", 5),
+		RetryBackoff:    getEnvAsDuration("KAFKA_RETRY_BACKOFF", 200*time.Millisecond),
+		RetryMaxBackoff: getEnvAsDuration("KAFKA_RETRY_MAX_BACKOFF", 10*time.Second),
+
+		DeliveryGuarantee: getEnv("KAFKA_DELIVERY_GUARANTEE", "at-least-once"),
+		CommitInterval:    getEnvAsDuration("KAFKA_COMMIT_INTERVAL", time.Second),
+
+		BatchSize:   getEnvAsInt("KAFKA_BATCH_SIZE", 1),
+		BatchLinger: getEnvAsDuration("KAFKA_BATCH_LINGER", 500*time.Millisecond),
+
+		Workers:           getEnvAsInt("KAFKA_WORKERS", 1),
+		MaxProcessingTime: getEnvAsDuration("KAFKA_MAX_PROCESSING_TIME", 500*time.Millisecond),
+
+		StallTimeout: getEnvAsDuration("KAFKA_STALL_TIMEOUT", 2*time.Minute),
+
+		Partitioner: getEnv("KAFKA_PARTITIONER", "hash"),
+
+		ProducerCompression: getEnv("KAFKA_PRODUCER_COMPRESSION", "snappy"),
+		ProducerBatchSize:   getEnvAsInt("KAFKA_PRODUCER_BATCH_SIZE", 100),
+		ProducerLinger:      getEnvAsDuration("KAFKA_PRODUCER_LINGER", 10*time.Millisecond),
+		ProducerMaxInFlight: getEnvAsInt("KAFKA_PRODUCER_MAX_IN_FLIGHT", 1000),
+	}
+
+	// Загружаем конфигурацию кэша заказов
+	config.Cache = CacheConfig{
+		Size:     getEnvAsInt("CACHE_SIZE", 1000),
+		TTL:      getEnvAsDuration("CACHE_TTL", 5*time.Minute),
+		StaleTTL: getEnvAsDuration("CACHE_STALE_TTL", 30*time.Second),
+
+		NegativeTTL: getEnvAsDuration("CACHE_NEGATIVE_TTL", 10*time.Second),
+	}
+
+	// Загружаем конфигурацию приёма заказов
+	config.Ingest = IngestConfig{
+		ConflictPolicy: getEnv("INGEST_CONFLICT_POLICY", "reject"),
+	}
+
+	// Загружаем конфигурацию публикации событий из outbox
+	config.Outbox = OutboxConfig{
+		Topic:           getEnv("OUTBOX_TOPIC", "orders.events"),
+		PollInterval:    getEnvAsDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		BatchSize:       getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+		RetryBackoff:    getEnvAsDuration("OUTBOX_RETRY_BACKOFF", time.Second),
+		RetryMaxBackoff: getEnvAsDuration("OUTBOX_RETRY_MAX_BACKOFF", time.Minute),
+		Retention:       getEnvAsDuration("OUTBOX_RETENTION", 24*time.Hour),
+		PruneInterval:   getEnvAsDuration("OUTBOX_PRUNE_INTERVAL", 10*time.Minute),
 	}
 
 	return &config, nil
 }
 
+type IngestConfig struct {
+	ConflictPolicy string // reject, overwrite или version
+}
+
+type OutboxConfig struct {
+	Topic           string        // топик событий order.ingested/order.updated
+	PollInterval    time.Duration // как часто проверять новые события
+	BatchSize       int           // событий за один проход
+	RetryBackoff    time.Duration // задержка после первой неудачной публикации
+	RetryMaxBackoff time.Duration // максимальная задержка между попытками
+	Retention       time.Duration // сколько хранить опубликованные события
+	PruneInterval   time.Duration // как часто удалять старые опубликованные события
+}
+
 type DatabaseConfig struct {
 	Host     string
 	Port     int
@@ -76,6 +225,26 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsBool получает переменную окружения как bool или возвращает значение по умолчанию
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsDuration получает переменную окружения как time.Duration или возвращает значение по умолчанию
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
 // getEnvAsSlice получает переменную окружения как slice строк или возвращает значение по умолчанию
 func getEnvAsSlice(key string, defaultValue []string) []string {
 	if value := os.Getenv(key); value != "" {
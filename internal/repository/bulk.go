@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"Order-tracker-service/internal/domain"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxBulkParams ограничение Postgres на число параметров в одном запросе
+const maxBulkParams = 65535
+
+// CreateBatch сохраняет пачку заказов в одной транзакции многострочными INSERT:
+// по нескольку запросов на таблицу вместо 3+N запросов на каждый заказ. Заказы,
+// UID которых уже есть в БД (в том числе повторы внутри пачки), обрабатываются
+// по одному согласно policy, как в Create. Результаты возвращаются в порядке
+// orders. Любая ошибка откатывает всю пачку. События outbox пишутся в той же
+// транзакции, как в Create.
+func (r *OrderRepos) CreateBatch(orders []*domain.Order, policy ConflictPolicy) (results []CreateResult, err error) {
+	results = make([]CreateResult, len(orders))
+	if len(orders) == 0 {
+		return results, nil
+	}
+
+	hashes := make([]string, len(orders))
+	for i, order := range orders {
+		if hashes[i], err = contentHash(order); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Вставляем заказы; при конфликте по order_uid строка не вставляется
+	orderRows := make([][]any, len(orders))
+	for i, order := range orders {
+		orderRows[i] = []any{
+			order.OrderUID,
+			order.TrackNumber,
+			order.Entry,
+			order.Locale,
+			order.InternalSignature,
+			order.CustomerID,
+			order.DeliveryService,
+			order.ShardKey,
+			order.SmID,
+			order.DateCreated,
+			order.OofShard,
+			hashes[i],
+		}
+	}
+	ids := make(map[string]int, len(orders))
+	err = bulkInsert(tx, "orders",
+		[]string{"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "content_hash"},
+		orderRows,
+		"ON CONFLICT (order_uid) DO NOTHING RETURNING id, order_uid",
+		func(rows *sql.Rows) error {
+			var id int
+			var uid string
+			if err := rows.Scan(&id, &uid); err != nil {
+				return err
+			}
+			ids[uid] = id
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Собираем строки delivery, payment и items для вставленных заказов
+	var deliveryRows, paymentRows, itemRows, outboxRows [][]any
+	var conflicting []int
+	for i, order := range orders {
+		orderID, ok := ids[order.OrderUID]
+		if !ok {
+			conflicting = append(conflicting, i)
+			continue
+		}
+		// Следующий заказ с тем же UID в пачке обрабатывается как конфликт
+		delete(ids, order.OrderUID)
+		results[i] = CreateInserted
+
+		d := order.Delivery
+		deliveryRows = append(deliveryRows, []any{orderID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email})
+
+		p := order.Payment
+		paymentRows = append(paymentRows, []any{orderID, p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount, p.PaymentDT, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee})
+
+		for _, item := range order.Items {
+			itemRows = append(itemRows, []any{orderID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status})
+		}
+
+		event, err := outboxRow(EventOrderIngested, order)
+		if err != nil {
+			return nil, err
+		}
+		outboxRows = append(outboxRows, event)
+	}
+
+	if err = bulkInsert(tx, "delivery",
+		[]string{"order_id", "name", "phone", "zip", "city", "address", "region", "email"},
+		deliveryRows, "", nil,
+	); err != nil {
+		return nil, err
+	}
+	if err = bulkInsert(tx, "payment",
+		[]string{"order_id", "transaction", "request_id", "currency", "provider", "amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"},
+		paymentRows, "", nil,
+	); err != nil {
+		return nil, err
+	}
+	if err = bulkInsert(tx, "items",
+		[]string{"order_id", "chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status"},
+		itemRows, "", nil,
+	); err != nil {
+		return nil, err
+	}
+
+	if err = bulkInsert(tx, "outbox",
+		[]string{"event_type", "order_uid", "payload"},
+		outboxRows, "", nil,
+	); err != nil {
+		return nil, err
+	}
+
+	// Конфликтующие заказы разрешаем по одному
+	for _, i := range conflicting {
+		if results[i], err = resolveConflict(tx, orders[i], hashes[i], policy); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// bulkInsert вставляет строки многострочными INSERT, разбивая их на запросы
+// так, чтобы не превысить лимит параметров. suffix дописывается к каждому
+// запросу (ON CONFLICT, RETURNING); если scan не nil, им читаются возвращённые строки.
+func bulkInsert(tx *sql.Tx, table string, columns []string, rows [][]any, suffix string, scan func(*sql.Rows) error) error {
+	perQuery := maxBulkParams / len(columns)
+
+	for start := 0; start < len(rows); start += perQuery {
+		chunk := rows[start:min(start+perQuery, len(rows))]
+
+		var query strings.Builder
+		fmt.Fprintf(&query, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+		args := make([]any, 0, len(chunk)*len(columns))
+		for i, row := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteByte('(')
+			for j, value := range row {
+				if j > 0 {
+					query.WriteByte(',')
+				}
+				args = append(args, value)
+				fmt.Fprintf(&query, "$%d", len(args))
+			}
+			query.WriteByte(')')
+		}
+		if suffix != "" {
+			query.WriteString(" " + suffix)
+		}
+
+		if scan == nil {
+			if _, err := tx.Exec(query.String(), args...); err != nil {
+				return fmt.Errorf("bulk insert into %s: %w", table, err)
+			}
+			continue
+		}
+
+		result, err := tx.Query(query.String(), args...)
+		if err != nil {
+			return fmt.Errorf("bulk insert into %s: %w", table, err)
+		}
+		for result.Next() {
+			if err := scan(result); err != nil {
+				result.Close()
+				return err
+			}
+		}
+		result.Close()
+		if err := result.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// IsPermanent сообщает, что ошибка сохранения вызвана самими данными и
+// повторная попытка с тем же заказом закончится так же: конфликт содержимого,
+// нарушение ограничений или некорректные значения. Остальные ошибки
+// (обрывы соединения, deadlock, serialization failure) считаются временными.
+func IsPermanent(err error) bool {
+	if errors.Is(err, ErrOrderConflict) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "22", // data exception
+			"23", // integrity constraint violation
+			"42": // syntax error or access rule violation
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"Order-tracker-service/internal/domain"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor возвращается, если курсор пагинации не удалось разобрать
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor позиция в списке заказов, отсортированном по (date_created, id) по убыванию
+type Cursor struct {
+	DateCreated time.Time
+	ID          int
+}
+
+// Encode кодирует курсор в непрозрачную для клиента строку
+func (c Cursor) Encode() string {
+	raw := c.DateCreated.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor разбирает строку, полученную из Cursor.Encode
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	dateStr, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	date, err := time.Parse(time.RFC3339Nano, dateStr)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{DateCreated: date, ID: id}, nil
+}
+
+// ListParams параметры постраничной выборки заказов
+type ListParams struct {
+	Limit     int
+	After     *Cursor // nil — первая страница
+	WithTotal bool    // посчитать общее количество заказов
+}
+
+// OrderPage страница заказов
+type OrderPage struct {
+	Orders     []*domain.Order
+	NextCursor *Cursor // nil — страница последняя
+	Total      *int    // заполняется, только если запрошен WithTotal
+}
+
+// Запросы страницы заказов. Первая страница выбирается без условия, чтобы
+// планировщик не получал предикат, зависящий от параметра.
+const (
+	listFirstPageQuery = `
+		SELECT id, order_uid, track_number, entry, locale, internal_signature, customer_id,
+		       delivery_service, shardkey, sm_id, date_created, oof_shard
+		FROM orders
+		ORDER BY date_created DESC, id DESC
+		LIMIT $1`
+	listAfterCursorQuery = `
+		SELECT id, order_uid, track_number, entry, locale, internal_signature, customer_id,
+		       delivery_service, shardkey, sm_id, date_created, oof_shard
+		FROM orders
+		WHERE (date_created, id) < ($1, $2)
+		ORDER BY date_created DESC, id DESC
+		LIMIT $3`
+)
+
+// List возвращает страницу заказов от новых к старым с keyset-пагинацией
+// по (date_created, id)
+func (r *OrderRepos) List(params ListParams) (*OrderPage, error) {
+	if params.Limit <= 0 {
+		return nil, fmt.Errorf("invalid limit: %d", params.Limit)
+	}
+
+	// Берём на одну строку больше, чтобы понять, есть ли следующая страница
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if params.After == nil {
+		rows, err = r.db.Query(listFirstPageQuery, params.Limit+1)
+	} else {
+		rows, err = r.db.Query(listAfterCursorQuery, params.After.DateCreated, params.After.ID, params.Limit+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rawOrders, err := scanOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OrderPage{}
+	if len(rawOrders) > params.Limit {
+		rawOrders = rawOrders[:params.Limit]
+		last := rawOrders[len(rawOrders)-1]
+		page.NextCursor = &Cursor{DateCreated: last.Order.DateCreated, ID: last.ID}
+	}
+
+	page.Orders, err = loadDetailsBatch(r.db, rawOrders)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.WithTotal {
+		var total int
+		if err := r.db.QueryRow(`SELECT count(*) FROM orders`).Scan(&total); err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{
+			name:   "utc",
+			cursor: Cursor{DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC), ID: 42},
+		},
+		{
+			name:   "nanoseconds",
+			cursor: Cursor{DateCreated: time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC), ID: 1},
+		},
+		{
+			name:   "non-utc zone",
+			cursor: Cursor{DateCreated: time.Date(2024, 5, 1, 13, 0, 0, 0, moscow), ID: 7},
+		},
+		{
+			name:   "zero id",
+			cursor: Cursor{DateCreated: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ID: 0},
+		},
+		{
+			name:   "large id",
+			cursor: Cursor{DateCreated: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ID: 1<<31 - 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.cursor.Encode())
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+			if !got.DateCreated.Equal(tt.cursor.DateCreated) || got.ID != tt.cursor.ID {
+				t.Errorf("DecodeCursor(Encode(%+v)) = %+v", tt.cursor, got)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty", input: ""},
+		{name: "not base64", input: "not a cursor!"},
+		{name: "padded base64", input: base64.URLEncoding.EncodeToString([]byte("2024-01-01T00:00:00Z|1"))},
+		{name: "no separator", input: encode("2024-01-01T00:00:00Z")},
+		{name: "bad date", input: encode("yesterday|1")},
+		{name: "bad id", input: encode("2024-01-01T00:00:00Z|abc")},
+		{name: "empty id", input: encode("2024-01-01T00:00:00Z|")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.input); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", tt.input, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"Order-tracker-service/internal/domain"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Типы событий outbox
+const (
+	EventOrderIngested = "order.ingested" // заказ сохранён впервые
+	EventOrderUpdated  = "order.updated"  // сохранённый заказ заменён новым содержимым
+)
+
+// outboxLockKey ключ advisory-блокировки, под которой публикуются события:
+// в каждый момент их публикует только один экземпляр сервиса, поэтому
+// порядок публикации совпадает с порядком id
+const outboxLockKey = 0x6f7574626f78 // "outbox"
+
+// OutboxEvent событие, ожидающее публикации
+type OutboxEvent struct {
+	ID        int64
+	EventType string
+	OrderUID  string
+	Payload   []byte // заказ в JSON текущей версии схемы
+	CreatedAt time.Time
+	Attempts  int // неудачных попыток публикации
+}
+
+// OutboxRepository хранилище событий для публикации
+type OutboxRepository interface {
+	PublishPending(limit int, publish func(event *OutboxEvent) error) (int, error)
+	PrunePublished(olderThan time.Time) (int64, error)
+}
+
+// outboxRow строка события для вставки в outbox
+func outboxRow(eventType string, order *domain.Order) ([]any, error) {
+	payload, err := json.Marshal(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+	return []any{eventType, order.OrderUID, payload}, nil
+}
+
+// insertOutbox записывает событие о заказе в транзакции, сохраняющей заказ
+func insertOutbox(tx *sql.Tx, eventType string, order *domain.Order) error {
+	row, err := outboxRow(eventType, order)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO outbox (event_type, order_uid, payload) VALUES ($1, $2, $3)`, row...)
+	return err
+}
+
+// PublishPending передаёт в publish до limit неопубликованных событий в
+// порядке id. Успешно опубликованные отмечаются published_at. На первой
+// ошибке обработка останавливается, чтобы не нарушить порядок: у события
+// увеличивается счётчик попыток, ошибка возвращается вызывающему.
+// Если события уже публикует другой экземпляр сервиса, возвращает 0.
+//
+// Публикация идёт вне транзакции: брокер может отвечать долго, а открытая
+// транзакция держала бы строки outbox. Единственность публикующего
+// экземпляра обеспечивает сессионная advisory-блокировка на выделенном
+// соединении, она снимается в конце прохода или при разрыве соединения.
+func (r *OrderRepos) PublishPending(limit int, publish func(event *OutboxEvent) error) (published int, err error) {
+	ctx := context.Background()
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, outboxLockKey).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+	defer func() {
+		if _, unlockErr := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, outboxLockKey); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to release outbox lock: %w", unlockErr)
+		}
+	}()
+
+	events, err := pendingOutbox(ctx, conn, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		publishedIDs []int64
+		failed       *OutboxEvent
+		publishErr   error
+	)
+	for _, event := range events {
+		if publishErr = publish(event); publishErr != nil {
+			failed = event
+			break
+		}
+		publishedIDs = append(publishedIDs, event.ID)
+	}
+
+	// Отметка о неудачной попытке сохраняется вместе с отметками
+	// об опубликованных событиях
+	if err := markOutbox(ctx, conn, publishedIDs, failed, publishErr); err != nil {
+		return 0, err
+	}
+	if failed != nil {
+		return len(publishedIDs), fmt.Errorf("failed to publish outbox event %d: %w", failed.ID, publishErr)
+	}
+	return len(publishedIDs), nil
+}
+
+// pendingOutbox читает до limit неопубликованных событий в порядке id
+func pendingOutbox(ctx context.Context, conn *sql.Conn, limit int) ([]*OutboxEvent, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT id, event_type, order_uid, payload, created_at, attempts
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		if err := rows.Scan(&event.ID, &event.EventType, &event.OrderUID, &event.Payload, &event.CreatedAt, &event.Attempts); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+// markOutbox отмечает опубликованные события и неудачную попытку
+// публикации failed одной короткой транзакцией
+func markOutbox(ctx context.Context, conn *sql.Conn, publishedIDs []int64, failed *OutboxEvent, publishErr error) error {
+	if len(publishedIDs) == 0 && failed == nil {
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(publishedIDs) > 0 {
+		if _, err := tx.Exec(`
+			UPDATE outbox SET published_at = now(), last_error = NULL WHERE id = ANY($1)`,
+			pq.Array(publishedIDs),
+		); err != nil {
+			return err
+		}
+	}
+	if failed != nil {
+		if _, err := tx.Exec(`
+			UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`,
+			failed.ID, publishErr.Error(),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PrunePublished удаляет события, опубликованные раньше olderThan
+func (r *OrderRepos) PrunePublished(olderThan time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM outbox WHERE published_at < $1`, olderThan)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -2,15 +2,33 @@ package repository
 
 import (
 	"Order-tracker-service/internal/domain"
+	"database/sql"
+	"errors"
+
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// ErrOrderNotFound возвращается, если заказа с указанным UID нет в БД
+var ErrOrderNotFound = errors.New("order not found")
+
 type OrderRepository interface {
-	Create(order *domain.Order) error
+	Create(order *domain.Order, policy ConflictPolicy) (CreateResult, error)
+	CreateBatch(orders []*domain.Order, policy ConflictPolicy) ([]CreateResult, error)
 	GetById(orderId string) (*domain.Order, error)
 	GetAll() ([]*domain.Order, error)
+	StreamRecent(limit int, fn func(order *domain.Order) error) error
+	List(params ListParams) (*OrderPage, error)
+	Search(params SearchParams) (*SearchResult, error)
 }
 
+const (
+	// streamChunkSize сколько заказов StreamRecent читает за один запрос
+	streamChunkSize = 500
+	// detailsBatchSize сколько заказов GetAll дозагружает за одну порцию
+	detailsBatchSize = 1000
+)
+
 type OrderRepos struct {
 	db *sqlx.DB
 }
@@ -19,10 +37,19 @@ func NewOrderRepository(db *sqlx.DB) *OrderRepos {
 	return &OrderRepos{db: db}
 }
 
-func (r *OrderRepos) Create(order *domain.Order) (err error) {
+// Create сохраняет заказ. Повторная доставка того же заказа (содержимое совпадает)
+// ничего не меняет и возвращает CreateDuplicate. Заказ с тем же UID, но другим
+// содержимым обрабатывается согласно policy. Вместе с заказом в той же
+// транзакции в outbox записывается событие order.ingested или order.updated.
+func (r *OrderRepos) Create(order *domain.Order, policy ConflictPolicy) (result CreateResult, err error) {
+	hash, err := contentHash(order)
+	if err != nil {
+		return CreateRejected, err
+	}
+
 	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return CreateRejected, err
 	}
 	defer func() {
 		if p := recover(); p != nil {
@@ -35,11 +62,13 @@ func (r *OrderRepos) Create(order *domain.Order) (err error) {
 		}
 	}()
 
-	// Вставляем заказ и получаем его id
+	// Вставляем заказ и получаем его id; если заказ с таким UID уже есть,
+	// строка не вставляется и конфликт разрешается отдельно
 	var orderID int
 	err = tx.QueryRow(`
-		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, content_hash)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+		ON CONFLICT (order_uid) DO NOTHING
 		RETURNING id`,
 		order.OrderUID,
 		order.TrackNumber,
@@ -52,11 +81,27 @@ func (r *OrderRepos) Create(order *domain.Order) (err error) {
 		order.SmID,
 		order.DateCreated,
 		order.OofShard,
+		hash,
 	).Scan(&orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		result, err = resolveConflict(tx, order, hash, policy)
+		return result, err
+	}
 	if err != nil {
-		return err
+		return CreateRejected, err
 	}
 
+	if err = insertDetails(tx, orderID, order); err != nil {
+		return CreateRejected, err
+	}
+	if err = insertOutbox(tx, EventOrderIngested, order); err != nil {
+		return CreateRejected, err
+	}
+	return CreateInserted, nil
+}
+
+// insertDetails вставляет delivery, payment и items заказа с указанным id
+func insertDetails(tx *sql.Tx, orderID int, order *domain.Order) (err error) {
 	// Вставляем delivery
 	_, err = tx.Exec(`
 		INSERT INTO delivery (order_id, name, phone, zip, city, address, region, email)
@@ -120,7 +165,7 @@ func (r *OrderRepos) Create(order *domain.Order) (err error) {
 	return nil
 }
 
-func (r *OrderRepos) GetById(orderUID string) (*domain.Order, error) {
+func (r *OrderRepos) GetById(orderUID string) (order *domain.Order, err error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
@@ -136,16 +181,25 @@ func (r *OrderRepos) GetById(orderUID string) (*domain.Order, error) {
 		}
 	}()
 
+	_, order, err = getOrder(tx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// getOrder читает заказ со всеми связанными данными и возвращает его id
+func getOrder(q queryer, orderUID string) (int, *domain.Order, error) {
 	var order domain.Order
 	var orderID int
 
 	// Получаем заказ и его id
-	row := tx.QueryRow(`
+	row := q.QueryRow(`
 		SELECT id, order_uid, track_number, entry, locale, internal_signature, customer_id, 
 		       delivery_service, shardkey, sm_id, date_created, oof_shard
 		FROM orders WHERE order_uid = $1`, orderUID)
 
-	err = row.Scan(
+	err := row.Scan(
 		&orderID,
 		&order.OrderUID,
 		&order.TrackNumber,
@@ -159,83 +213,22 @@ func (r *OrderRepos) GetById(orderUID string) (*domain.Order, error) {
 		&order.DateCreated,
 		&order.OofShard,
 	)
-	if err != nil {
-		return nil, err
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrOrderNotFound
 	}
-
-	// Получаем delivery
-	row = tx.QueryRow(`
-		SELECT name, phone, zip, city, address, region, email 
-		FROM delivery WHERE order_id = $1`, orderID)
-
-	err = row.Scan(
-		&order.Delivery.Name,
-		&order.Delivery.Phone,
-		&order.Delivery.Zip,
-		&order.Delivery.City,
-		&order.Delivery.Address,
-		&order.Delivery.Region,
-		&order.Delivery.Email,
-	)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
-	// Получаем payment
-	row = tx.QueryRow(`
-		SELECT transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
-		FROM payment WHERE order_id = $1`, orderID)
-
-	err = row.Scan(
-		&order.Payment.Transaction,
-		&order.Payment.RequestID,
-		&order.Payment.Currency,
-		&order.Payment.Provider,
-		&order.Payment.Amount,
-		&order.Payment.PaymentDT,
-		&order.Payment.Bank,
-		&order.Payment.DeliveryCost,
-		&order.Payment.GoodsTotal,
-		&order.Payment.CustomFee,
-	)
-	if err != nil {
-		return nil, err
+	// Получаем delivery, payment и items
+	if err = loadDetails(q, orderID, &order); err != nil {
+		return 0, nil, err
 	}
 
-	// Получаем items
-	rows, err := tx.Query(`
-		SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-		FROM items WHERE order_id = $1`, orderID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var item domain.Item
-		err = rows.Scan(
-			&item.ChrtID,
-			&item.TrackNumber,
-			&item.Price,
-			&item.RID,
-			&item.Name,
-			&item.Sale,
-			&item.Size,
-			&item.TotalPrice,
-			&item.NmID,
-			&item.Brand,
-			&item.Status,
-		)
-		if err != nil {
-			return nil, err
-		}
-		order.Items = append(order.Items, item)
-	}
-
-	return &order, nil
+	return orderID, &order, nil
 }
 
-func (r *OrderRepos) GetAll() ([]*domain.Order, error) {
+func (r *OrderRepos) GetAll() (orders []*domain.Order, err error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
@@ -255,18 +248,71 @@ func (r *OrderRepos) GetAll() ([]*domain.Order, error) {
 	rows, err := tx.Query(`
 		SELECT id, order_uid, track_number, entry, locale, internal_signature, customer_id,
 		       delivery_service, shardkey, sm_id, date_created, oof_shard
-		FROM orders`)
+		FROM orders
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	rawOrders, err := scanOrders(rows)
 	if err != nil {
 		return nil, err
 	}
 
-	type rawOrder struct {
-		ID    int
-		Order domain.Order
+	// Подгружаем delivery, payment и items порциями: по три запроса на порцию
+	// вместо трёх запросов на каждый заказ
+	orders = make([]*domain.Order, 0, len(rawOrders))
+	for start := 0; start < len(rawOrders); start += detailsBatchSize {
+		end := min(start+detailsBatchSize, len(rawOrders))
+		batch, err := loadDetailsBatch(tx, rawOrders[start:end])
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, batch...)
 	}
 
-	var rawOrders []rawOrder
+	return orders, nil
+}
+
+// StreamRecent передаёт в fn до limit самых свежих заказов (по date_created),
+// начиная с самого нового. Заказы читаются порциями, поэтому в памяти
+// одновременно находится не больше streamChunkSize заказов. Ошибка из fn
+// прерывает чтение и возвращается вызывающему.
+func (r *OrderRepos) StreamRecent(limit int, fn func(order *domain.Order) error) error {
+	var after *Cursor
+
+	for sent := 0; sent < limit; {
+		page, err := r.List(ListParams{Limit: min(streamChunkSize, limit-sent), After: after})
+		if err != nil {
+			return err
+		}
+
+		for _, order := range page.Orders {
+			if err := fn(order); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == nil {
+			return nil
+		}
+		sent += len(page.Orders)
+		after = page.NextCursor
+	}
+
+	return nil
+}
+
+// rawOrder заказ вместе с его суррогатным id в таблице orders
+type rawOrder struct {
+	ID    int
+	Order domain.Order
+}
 
+// scanOrders читает строки таблицы orders и закрывает rows
+func scanOrders(rows *sql.Rows) ([]rawOrder, error) {
+	defer rows.Close()
+
+	var rawOrders []rawOrder
 	for rows.Next() {
 		var ro rawOrder
 		if err := rows.Scan(
@@ -283,83 +329,201 @@ func (r *OrderRepos) GetAll() ([]*domain.Order, error) {
 			&ro.Order.DateCreated,
 			&ro.Order.OofShard,
 		); err != nil {
-			rows.Close()
 			return nil, err
 		}
 		rawOrders = append(rawOrders, ro)
 	}
-	rows.Close() // важно закрыть перед новыми Query!
 
-	var orders []*domain.Order
+	return rawOrders, rows.Err()
+}
 
-	// Теперь подгружаем delivery, payment, items для каждого заказа
-	for _, ro := range rawOrders {
-		orderID := ro.ID
+// loadDetailsBatch подгружает delivery, payment и items сразу для набора заказов
+// тремя запросами с order_id = ANY($1). Порядок заказов сохраняется.
+func loadDetailsBatch(q queryer, rawOrders []rawOrder) ([]*domain.Order, error) {
+	orders := make([]*domain.Order, len(rawOrders))
+	byID := make(map[int]*domain.Order, len(rawOrders))
+	ids := make([]int64, len(rawOrders))
+	for i, ro := range rawOrders {
 		order := ro.Order
+		orders[i] = &order
+		byID[ro.ID] = &order
+		ids[i] = int64(ro.ID)
+	}
+	if len(ids) == 0 {
+		return orders, nil
+	}
 
-		// delivery
-		row := tx.QueryRow(`SELECT name, phone, zip, city, address, region, email 
-		                    FROM delivery WHERE order_id=$1`, orderID)
-		if err := row.Scan(
-			&order.Delivery.Name,
-			&order.Delivery.Phone,
-			&order.Delivery.Zip,
-			&order.Delivery.City,
-			&order.Delivery.Address,
-			&order.Delivery.Region,
-			&order.Delivery.Email,
-		); err != nil {
+	// delivery
+	rows, err := q.Query(`
+		SELECT order_id, name, phone, zip, city, address, region, email
+		FROM delivery WHERE order_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var orderID int
+		var d domain.Delivery
+		if err := rows.Scan(&orderID, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email); err != nil {
+			rows.Close()
 			return nil, err
 		}
+		if order, ok := byID[orderID]; ok {
+			order.Delivery = d
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		// payment
-		row = tx.QueryRow(`SELECT transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
-		                   FROM payment WHERE order_id=$1`, orderID)
-		if err := row.Scan(
-			&order.Payment.Transaction,
-			&order.Payment.RequestID,
-			&order.Payment.Currency,
-			&order.Payment.Provider,
-			&order.Payment.Amount,
-			&order.Payment.PaymentDT,
-			&order.Payment.Bank,
-			&order.Payment.DeliveryCost,
-			&order.Payment.GoodsTotal,
-			&order.Payment.CustomFee,
+	// payment
+	rows, err = q.Query(`
+		SELECT order_id, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+		FROM payment WHERE order_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var orderID int
+		var p domain.Payment
+		if err := rows.Scan(
+			&orderID,
+			&p.Transaction,
+			&p.RequestID,
+			&p.Currency,
+			&p.Provider,
+			&p.Amount,
+			&p.PaymentDT,
+			&p.Bank,
+			&p.DeliveryCost,
+			&p.GoodsTotal,
+			&p.CustomFee,
 		); err != nil {
+			rows.Close()
 			return nil, err
 		}
+		if order, ok := byID[orderID]; ok {
+			order.Payment = p
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		// items
-		itemRows, err := tx.Query(`SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-		                           FROM items WHERE order_id=$1`, orderID)
-		if err != nil {
+	// items
+	rows, err = q.Query(`
+		SELECT order_id, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+		FROM items WHERE order_id = ANY($1)
+		ORDER BY order_id, id`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orderID int
+		var item domain.Item
+		if err := rows.Scan(
+			&orderID,
+			&item.ChrtID,
+			&item.TrackNumber,
+			&item.Price,
+			&item.RID,
+			&item.Name,
+			&item.Sale,
+			&item.Size,
+			&item.TotalPrice,
+			&item.NmID,
+			&item.Brand,
+			&item.Status,
+		); err != nil {
 			return nil, err
 		}
-		for itemRows.Next() {
-			var item domain.Item
-			if err := itemRows.Scan(
-				&item.ChrtID,
-				&item.TrackNumber,
-				&item.Price,
-				&item.RID,
-				&item.Name,
-				&item.Sale,
-				&item.Size,
-				&item.TotalPrice,
-				&item.NmID,
-				&item.Brand,
-				&item.Status,
-			); err != nil {
-				itemRows.Close()
-				return nil, err
-			}
+		if order, ok := byID[orderID]; ok {
 			order.Items = append(order.Items, item)
 		}
-		itemRows.Close()
+	}
+
+	return orders, rows.Err()
+}
+
+// queryer общий интерфейс *sql.Tx и *sqlx.DB для чтения
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// loadDetails подгружает delivery, payment и items заказа с указанным id
+func loadDetails(q queryer, orderID int, order *domain.Order) error {
+	// Получаем delivery
+	row := q.QueryRow(`
+		SELECT name, phone, zip, city, address, region, email 
+		FROM delivery WHERE order_id = $1`, orderID)
+
+	err := row.Scan(
+		&order.Delivery.Name,
+		&order.Delivery.Phone,
+		&order.Delivery.Zip,
+		&order.Delivery.City,
+		&order.Delivery.Address,
+		&order.Delivery.Region,
+		&order.Delivery.Email,
+	)
+	if err != nil {
+		return err
+	}
 
-		orders = append(orders, &order)
+	// Получаем payment
+	row = q.QueryRow(`
+		SELECT transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+		FROM payment WHERE order_id = $1`, orderID)
+
+	err = row.Scan(
+		&order.Payment.Transaction,
+		&order.Payment.RequestID,
+		&order.Payment.Currency,
+		&order.Payment.Provider,
+		&order.Payment.Amount,
+		&order.Payment.PaymentDT,
+		&order.Payment.Bank,
+		&order.Payment.DeliveryCost,
+		&order.Payment.GoodsTotal,
+		&order.Payment.CustomFee,
+	)
+	if err != nil {
+		return err
 	}
 
-	return orders, nil
+	// Получаем items
+	rows, err := q.Query(`
+		SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+		FROM items WHERE order_id = $1
+		ORDER BY id`, orderID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item domain.Item
+		err = rows.Scan(
+			&item.ChrtID,
+			&item.TrackNumber,
+			&item.Price,
+			&item.RID,
+			&item.Name,
+			&item.Sale,
+			&item.Size,
+			&item.TotalPrice,
+			&item.NmID,
+			&item.Brand,
+			&item.Status,
+		)
+		if err != nil {
+			return err
+		}
+		order.Items = append(order.Items, item)
+	}
+
+	return rows.Err()
 }
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"Order-tracker-service/internal/domain"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSearch возвращается при некорректных параметрах поиска
+var ErrInvalidSearch = errors.New("invalid search parameters")
+
+// SearchFilter фильтры поиска заказов. Пустые поля не учитываются,
+// заполненные объединяются через AND.
+type SearchFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+
+	// Поля payment
+	Transaction string
+
+	// Поля items: условия применяются к одной и той же позиции заказа
+	ItemRID string
+	NmID    int
+	Brand   string
+
+	// Поля delivery
+	Phone string
+	Email string
+
+	CreatedFrom *time.Time // включительно
+	CreatedTo   *time.Time // не включительно
+}
+
+// sortColumns допустимые поля сортировки и соответствующие им колонки
+var sortColumns = map[string]string{
+	"date_created": "o.date_created",
+	"order_uid":    "o.order_uid",
+	"customer_id":  "o.customer_id",
+	"track_number": "o.track_number",
+}
+
+// SearchParams параметры поиска заказов
+type SearchParams struct {
+	Filter SearchFilter
+	SortBy string // одно из ключей sortColumns, по умолчанию date_created
+	Desc   bool
+	Limit  int
+	Offset int
+}
+
+// SearchResult найденные заказы и общее количество совпадений
+type SearchResult struct {
+	Orders []*domain.Order
+	Total  int
+}
+
+// searchQuery собирает WHERE с позиционными параметрами Postgres
+type searchQuery struct {
+	conds []string
+	args  []any
+}
+
+// add добавляет условие; каждый '?' в cond заменяется следующим $N
+func (q *searchQuery) add(cond string, args ...any) {
+	for _, arg := range args {
+		q.args = append(q.args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(q.args)), 1)
+	}
+	q.conds = append(q.conds, cond)
+}
+
+// where возвращает WHERE-часть запроса или пустую строку
+func (q *searchQuery) where() string {
+	if len(q.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(q.conds, " AND ")
+}
+
+// buildSearchQuery переводит фильтр в условия по orders и EXISTS-подзапросы
+// по связанным таблицам, чтобы заказ не дублировался при нескольких совпадениях
+func buildSearchQuery(f SearchFilter) *searchQuery {
+	q := &searchQuery{}
+
+	if f.CustomerID != "" {
+		q.add("o.customer_id = ?", f.CustomerID)
+	}
+	if f.TrackNumber != "" {
+		q.add("o.track_number = ?", f.TrackNumber)
+	}
+	if f.DeliveryService != "" {
+		q.add("o.delivery_service = ?", f.DeliveryService)
+	}
+	if f.CreatedFrom != nil {
+		q.add("o.date_created >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		q.add("o.date_created < ?", *f.CreatedTo)
+	}
+
+	if f.Transaction != "" {
+		q.add("EXISTS (SELECT 1 FROM payment p WHERE p.order_id = o.id AND p.transaction = ?)", f.Transaction)
+	}
+
+	sub := &subquery{}
+	if f.Phone != "" {
+		sub.add("d.phone = ?", f.Phone)
+	}
+	if f.Email != "" {
+		sub.add("d.email = ?", f.Email)
+	}
+	if len(sub.conds) > 0 {
+		q.add("EXISTS (SELECT 1 FROM delivery d WHERE d.order_id = o.id AND "+sub.join()+")", sub.args...)
+	}
+
+	sub = &subquery{}
+	if f.ItemRID != "" {
+		sub.add("i.rid = ?", f.ItemRID)
+	}
+	if f.NmID != 0 {
+		sub.add("i.nm_id = ?", f.NmID)
+	}
+	if f.Brand != "" {
+		sub.add("i.brand = ?", f.Brand)
+	}
+	if len(sub.conds) > 0 {
+		q.add("EXISTS (SELECT 1 FROM items i WHERE i.order_id = o.id AND "+sub.join()+")", sub.args...)
+	}
+
+	return q
+}
+
+// subquery накапливает условия одного EXISTS-подзапроса
+type subquery struct {
+	conds []string
+	args  []any
+}
+
+func (s *subquery) add(cond string, arg any) {
+	s.conds = append(s.conds, cond)
+	s.args = append(s.args, arg)
+}
+
+func (s *subquery) join() string {
+	return strings.Join(s.conds, " AND ")
+}
+
+// Search ищет заказы по комбинации фильтров с сортировкой и offset-пагинацией
+func (r *OrderRepos) Search(params SearchParams) (*SearchResult, error) {
+	if params.Limit <= 0 || params.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit %d, offset %d", ErrInvalidSearch, params.Limit, params.Offset)
+	}
+
+	sortBy := params.SortBy
+	if sortBy == "" {
+		sortBy = "date_created"
+	}
+	column, ok := sortColumns[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidSearch, sortBy)
+	}
+	direction := "ASC"
+	if params.Desc {
+		direction = "DESC"
+	}
+
+	q := buildSearchQuery(params.Filter)
+
+	result := &SearchResult{}
+	if err := r.db.QueryRow(`SELECT count(*) FROM orders o `+q.where(), q.args...).Scan(&result.Total); err != nil {
+		return nil, err
+	}
+	if result.Total == 0 {
+		result.Orders = []*domain.Order{}
+		return result, nil
+	}
+
+	// id добавлен в сортировку, чтобы порядок страниц был стабильным
+	args := append(q.args, params.Limit, params.Offset)
+	rows, err := r.db.Query(fmt.Sprintf(`
+		SELECT o.id, o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id,
+		       o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard
+		FROM orders o
+		%s
+		ORDER BY %s %s, o.id %s
+		LIMIT $%d OFFSET $%d`,
+		q.where(), column, direction, direction, len(q.args)+1, len(q.args)+2), args...)
+	if err != nil {
+		return nil, err
+	}
+	rawOrders, err := scanOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Orders, err = loadDetailsBatch(r.db, rawOrders)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"Order-tracker-service/internal/domain"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrOrderConflict возвращается, если заказ с таким UID уже сохранён
+// с другим содержимым, а политика конфликтов — ConflictReject
+var ErrOrderConflict = errors.New("order already exists with different content")
+
+// ConflictPolicy определяет, что делать с заказом, UID которого уже есть в БД,
+// но содержимое отличается
+type ConflictPolicy string
+
+const (
+	ConflictReject    ConflictPolicy = "reject"    // вернуть ErrOrderConflict
+	ConflictOverwrite ConflictPolicy = "overwrite" // заменить сохранённый заказ новым
+	ConflictVersion   ConflictPolicy = "version"   // сохранить старое содержимое в order_versions и заменить
+)
+
+// ParseConflictPolicy разбирает политику конфликтов из конфигурации
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(s); policy {
+	case ConflictReject, ConflictOverwrite, ConflictVersion:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy %q: expected reject, overwrite or version", s)
+	}
+}
+
+// CreateResult итог сохранения заказа
+type CreateResult int
+
+const (
+	CreateRejected    CreateResult = iota // заказ не сохранён
+	CreateInserted                        // новый заказ
+	CreateDuplicate                       // точный дубликат уже сохранённого заказа, ничего не изменено
+	CreateOverwritten                     // сохранённый заказ заменён
+	CreateVersioned                       // сохранённый заказ заменён, прежняя версия заархивирована
+)
+
+func (r CreateResult) String() string {
+	switch r {
+	case CreateInserted:
+		return "inserted"
+	case CreateDuplicate:
+		return "duplicate"
+	case CreateOverwritten:
+		return "overwritten"
+	case CreateVersioned:
+		return "versioned"
+	default:
+		return "rejected"
+	}
+}
+
+// contentHash считает хэш содержимого заказа. Время приводится к UTC и
+// точности Postgres, чтобы хэш совпадал для заказа, прочитанного из БД.
+func contentHash(order *domain.Order) (string, error) {
+	normalized := *order
+	normalized.DateCreated = order.DateCreated.UTC().Truncate(time.Microsecond)
+
+	data, err := json.Marshal(&normalized)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal order: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// resolveConflict обрабатывает заказ, UID которого уже есть в БД.
+// Строка заказа блокируется до конца транзакции.
+func resolveConflict(tx *sql.Tx, order *domain.Order, hash string, policy ConflictPolicy) (CreateResult, error) {
+	var (
+		storedHash sql.NullString
+		version    int
+	)
+	err := tx.QueryRow(`
+		SELECT content_hash, version FROM orders WHERE order_uid = $1 FOR UPDATE`,
+		order.OrderUID,
+	).Scan(&storedHash, &version)
+	if err != nil {
+		return CreateRejected, err
+	}
+
+	// Для заказов, сохранённых до появления content_hash, считаем хэш по данным из БД
+	var stored *domain.Order
+	var orderID int
+	if !storedHash.Valid || policy == ConflictVersion {
+		if orderID, stored, err = getOrder(tx, order.OrderUID); err != nil {
+			return CreateRejected, err
+		}
+	}
+	if !storedHash.Valid {
+		if storedHash.String, err = contentHash(stored); err != nil {
+			return CreateRejected, err
+		}
+	}
+
+	if storedHash.String == hash {
+		return CreateDuplicate, nil
+	}
+
+	switch policy {
+	case ConflictOverwrite:
+		if err := overwrite(tx, order, hash, version+1); err != nil {
+			return CreateRejected, err
+		}
+		return CreateOverwritten, nil
+
+	case ConflictVersion:
+		payload, err := json.Marshal(stored)
+		if err != nil {
+			return CreateRejected, fmt.Errorf("failed to marshal stored order: %w", err)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO order_versions (order_id, order_uid, version, content_hash, payload)
+			VALUES ($1, $2, $3, $4, $5)`,
+			orderID, order.OrderUID, version, storedHash.String, payload,
+		); err != nil {
+			return CreateRejected, err
+		}
+		if err := overwrite(tx, order, hash, version+1); err != nil {
+			return CreateRejected, err
+		}
+		return CreateVersioned, nil
+
+	default:
+		return CreateRejected, fmt.Errorf("%w: %s", ErrOrderConflict, order.OrderUID)
+	}
+}
+
+// overwrite заменяет сохранённый заказ и все связанные данные новым содержимым
+// и записывает событие order.updated
+func overwrite(tx *sql.Tx, order *domain.Order, hash string, version int) error {
+	var orderID int
+	err := tx.QueryRow(`
+		UPDATE orders
+		SET track_number = $2, entry = $3, locale = $4, internal_signature = $5, customer_id = $6,
+		    delivery_service = $7, shardkey = $8, sm_id = $9, date_created = $10, oof_shard = $11,
+		    content_hash = $12, version = $13, updated_at = now()
+		WHERE order_uid = $1
+		RETURNING id`,
+		order.OrderUID,
+		order.TrackNumber,
+		order.Entry,
+		order.Locale,
+		order.InternalSignature,
+		order.CustomerID,
+		order.DeliveryService,
+		order.ShardKey,
+		order.SmID,
+		order.DateCreated,
+		order.OofShard,
+		hash,
+		version,
+	).Scan(&orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"delivery", "payment", "items"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE order_id = $1`, orderID); err != nil {
+			return err
+		}
+	}
+
+	if err := insertDetails(tx, orderID, order); err != nil {
+		return err
+	}
+	return insertOutbox(tx, EventOrderUpdated, order)
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"Order-tracker-service/internal/domain"
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultCacheSize используется, если размер кэша не задан в конфигурации
+const defaultCacheSize = 100
+
+// cacheEntry элемент списка LRU
+type cacheEntry struct {
+	key        string
+	order      *domain.Order
+	expiresAt  time.Time // нулевое значение — запись не устаревает
+	refreshing bool      // запись устарела и уже обновляется в фоне
+}
+
+// lruCache потокобезопасный кэш заказов ограниченного размера
+// с вытеснением давно не использованных записей и сроком жизни записей
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration // срок свежести записи, 0 — бессрочно
+	staleTTL time.Duration // сколько после ttl можно отдавать устаревшую запись, пока она обновляется
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+// newLRUCache создает кэш на capacity записей
+func newLRUCache(capacity int, ttl, staleTTL time.Duration) *lruCache {
+	if capacity <= 0 {
+		capacity = defaultCacheSize
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if staleTTL < 0 || ttl == 0 {
+		staleTTL = 0
+	}
+	return &lruCache{
+		capacity: capacity,
+		ttl:      ttl,
+		staleTTL: staleTTL,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+// Get возвращает заказ из кэша и помечает его как недавно использованный.
+// Если запись устарела, но ещё в окне stale-while-revalidate, она отдаётся,
+// а refresh=true возвращается ровно одному вызывающему, который должен её обновить.
+func (c *lruCache) Get(key string) (order *domain.Order, ok bool, refresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, found := c.items[key]
+	if !found {
+		return nil, false, false
+	}
+	entry := el.Value.(*cacheEntry)
+
+	if !entry.expiresAt.IsZero() {
+		now := time.Now()
+		if now.After(entry.expiresAt.Add(c.staleTTL)) {
+			// Запись протухла окончательно
+			c.remove(el)
+			return nil, false, false
+		}
+		if now.After(entry.expiresAt) && !entry.refreshing {
+			entry.refreshing = true
+			refresh = true
+		}
+	}
+
+	c.ll.MoveToFront(el)
+	return entry.order, true, refresh
+}
+
+// Set кладёт заказ в кэш, перезаписывая существующую запись
+func (c *lruCache) Set(key string, order *domain.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.update(el, order)
+		return
+	}
+	c.insert(key, order)
+}
+
+// SetIfAbsent кладёт заказ в кэш, только если записи с таким ключом ещё нет.
+// Нужен для пути чтения из БД: прочитанные данные не должны затирать
+// более свежую запись, положенную в кэш параллельным Create.
+func (c *lruCache) SetIfAbsent(key string, order *domain.Order) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; ok {
+		return false
+	}
+	c.insert(key, order)
+	return true
+}
+
+// Warm добавляет запись в конец списка LRU при прогреве кэша: заказы
+// приходят от новых к старым, и более старые должны вытесняться первыми.
+// Существующие записи не трогаются. Возвращает false, если кэш уже заполнен.
+func (c *lruCache) Warm(key string, order *domain.Order) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ll.Len() >= c.capacity {
+		return false
+	}
+	if _, ok := c.items[key]; !ok {
+		c.items[key] = c.ll.PushBack(&cacheEntry{key: key, order: order, expiresAt: c.expiry()})
+	}
+	return true
+}
+
+// CompleteRefresh сохраняет результат фонового обновления.
+// Если запись успели перезаписать через Set или вытеснить, результат отбрасывается.
+func (c *lruCache) CompleteRefresh(key string, order *domain.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok && el.Value.(*cacheEntry).refreshing {
+		c.update(el, order)
+	}
+}
+
+// AbortRefresh снимает отметку об обновлении, чтобы следующий запрос повторил попытку
+func (c *lruCache) AbortRefresh(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*cacheEntry).refreshing = false
+	}
+}
+
+// Delete удаляет запись из кэша
+func (c *lruCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len возвращает текущее количество записей
+func (c *lruCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Cap возвращает максимальное количество записей
+func (c *lruCache) Cap() int {
+	return c.capacity
+}
+
+// expiry вычисляет срок свежести новой записи. Вызывается под c.mu.
+func (c *lruCache) expiry() time.Time {
+	if c.ttl == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(c.ttl)
+}
+
+// update обновляет существующую запись. Вызывается под c.mu.
+func (c *lruCache) update(el *list.Element, order *domain.Order) {
+	entry := el.Value.(*cacheEntry)
+	entry.order = order
+	entry.expiresAt = c.expiry()
+	entry.refreshing = false
+	c.ll.MoveToFront(el)
+}
+
+// insert добавляет новую запись и вытесняет самую старую при переполнении.
+// Вызывается под c.mu.
+func (c *lruCache) insert(key string, order *domain.Order) {
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, order: order, expiresAt: c.expiry()})
+
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+	}
+}
+
+// remove удаляет элемент списка. Вызывается под c.mu.
+func (c *lruCache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"Order-tracker-service/internal/domain"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testOrder(uid string) *domain.Order {
+	return &domain.Order{OrderUID: uid}
+}
+
+// keys возвращает ключи кэша от недавно использованных к давним
+func keys(c *lruCache) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var result []string
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		result = append(result, el.Value.(*cacheEntry).key)
+	}
+	return result
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		ops      func(c *lruCache)
+		want     []string
+	}{
+		{
+			name:     "evicts least recently inserted",
+			capacity: 2,
+			ops: func(c *lruCache) {
+				c.Set("a", testOrder("a"))
+				c.Set("b", testOrder("b"))
+				c.Set("c", testOrder("c"))
+			},
+			want: []string{"c", "b"},
+		},
+		{
+			name:     "get marks entry as recently used",
+			capacity: 2,
+			ops: func(c *lruCache) {
+				c.Set("a", testOrder("a"))
+				c.Set("b", testOrder("b"))
+				c.Get("a")
+				c.Set("c", testOrder("c"))
+			},
+			want: []string{"c", "a"},
+		},
+		{
+			name:     "overwrite does not evict",
+			capacity: 2,
+			ops: func(c *lruCache) {
+				c.Set("a", testOrder("a"))
+				c.Set("b", testOrder("b"))
+				c.Set("a", testOrder("a"))
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name:     "set if absent keeps existing entry",
+			capacity: 2,
+			ops: func(c *lruCache) {
+				c.Set("a", testOrder("a"))
+				c.Set("b", testOrder("b"))
+				c.SetIfAbsent("a", testOrder("stale"))
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name:     "warm appends older entries to the back",
+			capacity: 3,
+			ops: func(c *lruCache) {
+				c.Set("live", testOrder("live"))
+				c.Warm("new", testOrder("new"))
+				c.Warm("old", testOrder("old"))
+				c.Warm("older", testOrder("older"))
+			},
+			want: []string{"live", "new", "old"},
+		},
+		{
+			name:     "delete frees a slot",
+			capacity: 2,
+			ops: func(c *lruCache) {
+				c.Set("a", testOrder("a"))
+				c.Set("b", testOrder("b"))
+				c.Delete("a")
+				c.Set("c", testOrder("c"))
+			},
+			want: []string{"c", "b"},
+		},
+		{
+			name:     "non-positive capacity uses default",
+			capacity: 0,
+			ops: func(c *lruCache) {
+				c.Set("a", testOrder("a"))
+			},
+			want: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newLRUCache(tt.capacity, 0, 0)
+			tt.ops(c)
+
+			if got := keys(c); !slices.Equal(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+			if c.Len() != len(tt.want) {
+				t.Errorf("Len() = %d, want %d", c.Len(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestLRUCacheCapacity(t *testing.T) {
+	c := newLRUCache(0, 0, 0)
+	if c.Cap() != defaultCacheSize {
+		t.Fatalf("Cap() = %d, want %d", c.Cap(), defaultCacheSize)
+	}
+
+	for i := range 3 * defaultCacheSize {
+		key := fmt.Sprintf("order-%d", i)
+		c.Set(key, testOrder(key))
+		if c.Len() > c.Cap() {
+			t.Fatalf("Len() = %d exceeds capacity %d", c.Len(), c.Cap())
+		}
+	}
+	if c.Len() != c.Cap() {
+		t.Errorf("Len() = %d, want %d", c.Len(), c.Cap())
+	}
+}
+
+func TestLRUCacheWarmStopsWhenFull(t *testing.T) {
+	c := newLRUCache(2, 0, 0)
+	if !c.Warm("a", testOrder("a")) || !c.Warm("b", testOrder("b")) {
+		t.Fatal("Warm rejected entry while cache had room")
+	}
+	if c.Warm("c", testOrder("c")) {
+		t.Error("Warm accepted entry into full cache")
+	}
+}
+
+// age сдвигает срок свежести записи в прошлое на d
+func age(c *lruCache, key string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.items[key].Value.(*cacheEntry)
+	entry.expiresAt = time.Now().Add(-d)
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	const ttl, staleTTL = time.Minute, 30 * time.Second
+
+	tests := []struct {
+		name        string
+		staleTTL    time.Duration
+		age         time.Duration // насколько запись просрочена, 0 — свежая
+		wantOK      bool
+		wantRefresh bool
+		wantRemoved bool
+	}{
+		{
+			name:   "fresh entry",
+			wantOK: true,
+		},
+		{
+			name:        "stale entry is served and refreshed",
+			staleTTL:    staleTTL,
+			age:         10 * time.Second,
+			wantOK:      true,
+			wantRefresh: true,
+		},
+		{
+			name:        "entry past stale window is removed",
+			staleTTL:    staleTTL,
+			age:         time.Minute,
+			wantRemoved: true,
+		},
+		{
+			name:        "expired entry without stale window is removed",
+			age:         time.Second,
+			wantRemoved: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newLRUCache(10, ttl, tt.staleTTL)
+			c.Set("a", testOrder("a"))
+			if tt.age > 0 {
+				age(c, "a", tt.age)
+			}
+
+			order, ok, refresh := c.Get("a")
+			if ok != tt.wantOK || refresh != tt.wantRefresh {
+				t.Fatalf("Get() ok=%v refresh=%v, want ok=%v refresh=%v", ok, refresh, tt.wantOK, tt.wantRefresh)
+			}
+			if ok && order.OrderUID != "a" {
+				t.Errorf("Get() order = %s, want a", order.OrderUID)
+			}
+			if removed := c.Len() == 0; removed != tt.wantRemoved {
+				t.Errorf("entry removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestLRUCacheNoTTL(t *testing.T) {
+	c := newLRUCache(10, 0, time.Minute)
+	c.Set("a", testOrder("a"))
+
+	c.mu.Lock()
+	expiresAt := c.items["a"].Value.(*cacheEntry).expiresAt
+	c.mu.Unlock()
+	if !expiresAt.IsZero() {
+		t.Fatalf("entry expires at %v without TTL", expiresAt)
+	}
+	if _, ok, refresh := c.Get("a"); !ok || refresh {
+		t.Errorf("Get() ok=%v refresh=%v, want ok=true refresh=false", ok, refresh)
+	}
+}
+
+func TestLRUCacheStaleRefreshedOnce(t *testing.T) {
+	c := newLRUCache(10, time.Minute, time.Minute)
+	c.Set("a", testOrder("a"))
+	age(c, "a", time.Second)
+
+	if _, _, refresh := c.Get("a"); !refresh {
+		t.Fatal("first Get of stale entry did not ask for refresh")
+	}
+	if _, ok, refresh := c.Get("a"); !ok || refresh {
+		t.Fatalf("second Get ok=%v refresh=%v, want stale entry without refresh", ok, refresh)
+	}
+
+	// Неудачное обновление: следующий запрос пробует снова
+	c.AbortRefresh("a")
+	if _, _, refresh := c.Get("a"); !refresh {
+		t.Fatal("Get after AbortRefresh did not ask for refresh")
+	}
+
+	c.CompleteRefresh("a", testOrder("a2"))
+	order, ok, refresh := c.Get("a")
+	if !ok || refresh || order.OrderUID != "a2" {
+		t.Errorf("Get after refresh = %v ok=%v refresh=%v, want fresh a2", order, ok, refresh)
+	}
+}
+
+func TestLRUCacheCompleteRefreshAfterSet(t *testing.T) {
+	c := newLRUCache(10, time.Minute, time.Minute)
+	c.Set("a", testOrder("a"))
+	age(c, "a", time.Second)
+	c.Get("a")
+
+	// Create успел записать более свежий заказ, результат обновления отбрасывается
+	c.Set("a", testOrder("created"))
+	c.CompleteRefresh("a", testOrder("refreshed"))
+
+	if order, _, _ := c.Get("a"); order.OrderUID != "created" {
+		t.Errorf("order = %s, want created", order.OrderUID)
+	}
+
+	// Для вытесненной записи результат обновления тоже не сохраняется
+	c.Delete("a")
+	c.CompleteRefresh("a", testOrder("refreshed"))
+	if c.Len() != 0 {
+		t.Error("CompleteRefresh recreated a removed entry")
+	}
+}
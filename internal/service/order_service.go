@@ -1,101 +1,319 @@
 package service
 
 import (
+	"Order-tracker-service/config"
 	"Order-tracker-service/internal/domain"
 	"Order-tracker-service/internal/repository"
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"sync"
 )
 
+// ErrOrderNotFound возвращается, если заказа с указанным UID не существует
+var ErrOrderNotFound = repository.ErrOrderNotFound
+
+// ErrOrderConflict возвращается, если заказ с таким UID уже сохранён с другим
+// содержимым, а политика конфликтов запрещает его заменять
+var ErrOrderConflict = repository.ErrOrderConflict
+
+// PermanentError ошибка обработки заказа, повтор которой не поможет.
+// Консьюмер Kafka не повторяет такие ошибки, а сразу отправляет сообщение в DLQ.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string   { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error   { return e.Err }
+func (e *PermanentError) Permanent() bool { return true }
+
+// errCacheFull останавливает прогрев, когда кэш заполнен
+var errCacheFull = errors.New("cache is full")
+
+// warmUpReportEvery как часто RestoreCache сообщает о ходе прогрева
+const warmUpReportEvery = 1000
+
+// WarmUpStatus состояние прогрева кэша при старте
+type WarmUpStatus struct {
+	Loaded int    `json:"loaded"`
+	Target int    `json:"target"`
+	Done   bool   `json:"done"`
+	Error  string `json:"error,omitempty"`
+}
+
 type OrderService struct {
 	repo      repository.OrderRepository
-	cache     map[string]*domain.Order
-	mu        sync.RWMutex
+	cache     *lruCache
+	missing   *lruCache // негативный кэш UID, которых нет в БД; nil — выключен
+	loads     *loadGroup
 	CacheSize int
+
+	conflictPolicy repository.ConflictPolicy
+
+	warmUp WarmUpStatus
+	warmMu sync.RWMutex
 }
 
-func NewOrderService(repo repository.OrderRepository, Size int) *OrderService {
+func NewOrderService(repo repository.OrderRepository, cfg *config.CacheConfig, policy repository.ConflictPolicy) *OrderService {
+	cache := newLRUCache(cfg.Size, cfg.TTL, cfg.StaleTTL)
+
+	var missing *lruCache
+	if cfg.NegativeTTL > 0 {
+		missing = newLRUCache(cfg.Size, cfg.NegativeTTL, 0)
+	}
+
 	return &OrderService{
 		repo:      repo,
-		cache:     make(map[string]*domain.Order),
-		CacheSize: Size,
-	}
-}
+		cache:     cache,
+		missing:   missing,
+		loads:     newLoadGroup(),
+		CacheSize: cache.Cap(),
 
-func (s *OrderService) CheckCache() bool {
-	// Ограничиваем размер кэша простым порогом по количеству ключей
-	return len(s.cache) < 100
+		conflictPolicy: policy,
+	}
 }
 
 func (s *OrderService) GetInfo(orderUID string) (*domain.Order, error) {
-	// 1) Проверяем кэш под RLock
-	s.mu.RLock()
-	orderFromCache, found := s.cache[orderUID]
-	s.mu.RUnlock()
+	// 1) Проверяем кэш; устаревшую запись отдаём сразу, а обновляем в фоне
+	orderFromCache, found, refresh := s.cache.Get(orderUID)
+	if refresh {
+		go s.refresh(orderUID)
+	}
 	if found && orderFromCache != nil {
 		return orderFromCache, nil
 	}
+	if s.isMissing(orderUID) {
+		return nil, ErrOrderNotFound
+	}
+
+	// 2) Если в кэше нет — читаем из БД; параллельные промахи по одному
+	// заказу объединяются в один запрос
+	orderFromDB, err := s.load(orderUID)
+	if errors.Is(err, ErrOrderNotFound) {
+		s.markMissing(orderUID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 3) Кладём в кэш для последующих запросов, не затирая запись,
+	// которую мог положить параллельный Create
+	s.cache.SetIfAbsent(orderUID, orderFromDB)
 
-	// 2) Если в кэше нет — читаем из БД
+	return orderFromDB, nil
+}
+
+// GetInfoFresh читает заказ из БД в обход кэша и обновляет запись в кэше
+func (s *OrderService) GetInfoFresh(orderUID string) (*domain.Order, error) {
 	orderFromDB, err := s.repo.GetById(orderUID)
+	if errors.Is(err, ErrOrderNotFound) {
+		s.cache.Delete(orderUID)
+		s.markMissing(orderUID)
+	}
 	if err != nil {
 		return nil, err
 	}
-	if orderFromDB == nil {
-		return nil, nil
+
+	s.cache.Set(orderUID, orderFromDB)
+	s.unmarkMissing(orderUID)
+	return orderFromDB, nil
+}
+
+// load читает заказ из БД, разделяя результат между параллельными вызовами
+func (s *OrderService) load(orderUID string) (*domain.Order, error) {
+	order, err, _ := s.loads.Do(orderUID, func() (*domain.Order, error) {
+		return s.repo.GetById(orderUID)
+	})
+	return order, err
+}
+
+// refresh перечитывает устаревшую запись кэша из БД
+func (s *OrderService) refresh(orderUID string) {
+	orderFromDB, err := s.load(orderUID)
+	if errors.Is(err, ErrOrderNotFound) {
+		// Заказ удалили из БД — убираем его и из кэша
+		s.cache.Delete(orderUID)
+		s.markMissing(orderUID)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to refresh cached order %s: %v", orderUID, err)
+		s.cache.AbortRefresh(orderUID)
+		return
 	}
+	s.cache.CompleteRefresh(orderUID, orderFromDB)
+}
 
-	// 3) Кладём в кэш для последующих запросов
-	if s.CheckCache() {
-		s.mu.Lock()
-		s.cache[orderUID] = orderFromDB
-		s.mu.Unlock()
+// isMissing проверяет, закэширован ли UID как отсутствующий в БД
+func (s *OrderService) isMissing(orderUID string) bool {
+	if s.missing == nil {
+		return false
 	}
+	_, found, _ := s.missing.Get(orderUID)
+	return found
+}
 
-	return orderFromDB, nil
+// markMissing запоминает, что заказа с таким UID нет в БД
+func (s *OrderService) markMissing(orderUID string) {
+	if s.missing != nil {
+		s.missing.Set(orderUID, nil)
+	}
 }
 
-func (s *OrderService) Create(order *domain.Order) error {
+// unmarkMissing снимает негативную запись, когда заказ появился
+func (s *OrderService) unmarkMissing(orderUID string) {
+	if s.missing != nil {
+		s.missing.Delete(orderUID)
+	}
+}
 
-	if err := s.repo.Create(order); err != nil {
-		return err
+// Create сохраняет заказ согласно политике конфликтов сервиса и сообщает,
+// что именно произошло: вставка, дубликат, перезапись или новая версия
+func (s *OrderService) Create(order *domain.Order) (repository.CreateResult, error) {
+	result, err := s.repo.Create(order, s.conflictPolicy)
+	if err != nil {
+		return result, err
 	}
-	if s.CheckCache() {
-		s.mu.Lock()
-		s.cache[order.OrderUID] = order
-		s.mu.Unlock()
+
+	// Дубликат совпадает с тем, что уже сохранено, кэш трогать не нужно
+	if result != repository.CreateDuplicate {
+		s.cache.Set(order.OrderUID, order)
+		s.unmarkMissing(order.OrderUID)
 	}
-	return nil
+	return result, nil
 }
 
-func (s *OrderService) RestoreCache(order *domain.Order) error {
-	orders, err := s.repo.GetAll()
+// CreateBatch сохраняет пачку заказов одной транзакцией. При ошибке
+// не сохраняется ни один заказ пачки.
+func (s *OrderService) CreateBatch(orders []*domain.Order) ([]repository.CreateResult, error) {
+	results, err := s.repo.CreateBatch(orders, s.conflictPolicy)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	s.mu.Lock()
-	for _, order := range orders {
-		s.cache[order.OrderUID] = order
+	for i, order := range orders {
+		if results[i] != repository.CreateDuplicate {
+			s.cache.Set(order.OrderUID, order)
+			s.unmarkMissing(order.OrderUID)
+		}
 	}
-	s.mu.Unlock()
+	return results, nil
+}
 
-	log.Printf("restore cache of order %v", len(orders))
+// ListOrders возвращает страницу заказов от новых к старым.
+// Список читается напрямую из БД, кэш не используется и не заполняется.
+func (s *OrderService) ListOrders(params repository.ListParams) (*repository.OrderPage, error) {
+	return s.repo.List(params)
+}
+
+// ErrInvalidSearch возвращается при некорректных параметрах поиска
+var ErrInvalidSearch = repository.ErrInvalidSearch
+
+// SearchOrders ищет заказы по фильтрам. Как и ListOrders, работает напрямую с БД.
+func (s *OrderService) SearchOrders(params repository.SearchParams) (*repository.SearchResult, error) {
+	return s.repo.Search(params)
+}
+
+// RestoreCache прогревает кэш самыми свежими заказами из БД, пока он не заполнится.
+// Заказы читаются потоково; ход прогрева доступен через WarmUpStatus.
+func (s *OrderService) RestoreCache(ctx context.Context) error {
+	target := s.cache.Cap()
+	s.setWarmUp(WarmUpStatus{Target: target})
+	log.Printf("Restoring order cache: up to %d most recent orders", target)
 
+	loaded := 0
+	err := s.repo.StreamRecent(target, func(order *domain.Order) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !s.cache.Warm(order.OrderUID, order) {
+			return errCacheFull
+		}
+
+		loaded++
+		if loaded%warmUpReportEvery == 0 {
+			s.setWarmUp(WarmUpStatus{Loaded: loaded, Target: target})
+			log.Printf("Restoring order cache: %d/%d", loaded, target)
+		}
+		return nil
+	})
+	if errors.Is(err, errCacheFull) {
+		err = nil
+	}
+
+	status := WarmUpStatus{Loaded: loaded, Target: target, Done: true}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	s.setWarmUp(status)
+
+	if err != nil {
+		return fmt.Errorf("failed to restore cache after %d orders: %w", loaded, err)
+	}
+	log.Printf("Order cache restored: %d orders", loaded)
 	return nil
 }
 
+// WarmUpStatus возвращает текущее состояние прогрева кэша
+func (s *OrderService) WarmUpStatus() WarmUpStatus {
+	s.warmMu.RLock()
+	defer s.warmMu.RUnlock()
+	return s.warmUp
+}
+
+// Ready сообщает, завершён ли прогрев кэша
+func (s *OrderService) Ready() bool {
+	return s.WarmUpStatus().Done
+}
+
+func (s *OrderService) setWarmUp(status WarmUpStatus) {
+	s.warmMu.Lock()
+	s.warmUp = status
+	s.warmMu.Unlock()
+}
+
 // HandleOrder обрабатывает заказ, полученный из Kafka
 func (s *OrderService) HandleOrder(ctx context.Context, order *domain.Order) error {
 	log.Printf("Processing order from Kafka: %s", order.OrderUID)
 
-	// Сохраняем заказ в базу данных
-	if err := s.Create(order); err != nil {
+	// Сохраняем заказ в базу данных; повторная доставка того же заказа — не ошибка
+	result, err := s.Create(order)
+	if err != nil {
 		log.Printf("Failed to save order %s to database: %v", order.OrderUID, err)
+		if repository.IsPermanent(err) {
+			return &PermanentError{Err: err}
+		}
 		return err
 	}
 
-	log.Printf("Successfully processed order from Kafka: %s", order.OrderUID)
+	log.Printf("Successfully processed order from Kafka: %s (%s)", order.OrderUID, result)
+	return nil
+}
+
+// HandleOrders обрабатывает пачку заказов, полученных из Kafka
+func (s *OrderService) HandleOrders(ctx context.Context, orders []*domain.Order) error {
+	log.Printf("Processing batch of %d orders from Kafka", len(orders))
+
+	results, err := s.CreateBatch(orders)
+	if err != nil {
+		log.Printf("Failed to save batch of %d orders to database: %v", len(orders), err)
+		if repository.IsPermanent(err) {
+			return &PermanentError{Err: err}
+		}
+		return err
+	}
+
+	counts := make(map[repository.CreateResult]int)
+	for _, result := range results {
+		counts[result]++
+	}
+	log.Printf("Successfully processed batch of %d orders from Kafka: inserted %d, duplicate %d, overwritten %d, versioned %d",
+		len(orders),
+		counts[repository.CreateInserted],
+		counts[repository.CreateDuplicate],
+		counts[repository.CreateOverwritten],
+		counts[repository.CreateVersioned],
+	)
 	return nil
 }
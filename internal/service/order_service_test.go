@@ -0,0 +1,227 @@
+package service
+
+import (
+	"Order-tracker-service/config"
+	"Order-tracker-service/internal/domain"
+	"Order-tracker-service/internal/repository"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubRepo хранилище заказов в памяти. GetById считает вызовы и, если
+// задан release, не возвращается, пока он не закрыт.
+type stubRepo struct {
+	repository.OrderRepository
+
+	mu      sync.Mutex
+	orders  map[string]*domain.Order
+	err     error // ошибка GetById вместо чтения orders
+	release chan struct{}
+	calls   atomic.Int64
+}
+
+func newStubRepo(orders ...*domain.Order) *stubRepo {
+	r := &stubRepo{orders: make(map[string]*domain.Order)}
+	for _, order := range orders {
+		r.orders[order.OrderUID] = order
+	}
+	return r
+}
+
+func (r *stubRepo) GetById(orderUID string) (*domain.Order, error) {
+	r.calls.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	order, ok := r.orders[orderUID]
+	if !ok {
+		return nil, repository.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func (r *stubRepo) Create(order *domain.Order, _ repository.ConflictPolicy) (repository.CreateResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orders[order.OrderUID] = order
+	return repository.CreateInserted, nil
+}
+
+func newTestService(repo repository.OrderRepository) *OrderService {
+	return NewOrderService(repo, &config.CacheConfig{Size: 10, NegativeTTL: time.Minute}, repository.ConflictReject)
+}
+
+// getConcurrently вызывает GetInfo из n горутин, пока GetById репозитория
+// заблокирован, и возвращает результаты всех вызовов
+func getConcurrently(t *testing.T, s *OrderService, repo *stubRepo, orderUID string, n int) ([]*domain.Order, []error) {
+	t.Helper()
+	repo.release = make(chan struct{})
+
+	orders := make([]*domain.Order, n)
+	errs := make([]error, n)
+	var started, wg sync.WaitGroup
+	started.Add(n)
+	wg.Add(n)
+	for i := range n {
+		go func() {
+			defer wg.Done()
+			started.Done()
+			orders[i], errs[i] = s.GetInfo(orderUID)
+		}()
+	}
+
+	// Даём всем вызовам дойти до ожидания общей загрузки
+	started.Wait()
+	time.Sleep(20 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+	repo.release = nil
+	return orders, errs
+}
+
+func TestGetInfoCoalescesConcurrentMisses(t *testing.T) {
+	const callers = 50
+	stored := testOrder("order-1")
+	repo := newStubRepo(stored)
+	s := newTestService(repo)
+
+	orders, errs := getConcurrently(t, s, repo, "order-1", callers)
+
+	if got := repo.calls.Load(); got != 1 {
+		t.Fatalf("GetById called %d times for %d concurrent misses, want 1", got, callers)
+	}
+	for i := range callers {
+		if errs[i] != nil || orders[i] != stored {
+			t.Errorf("caller %d got %v, %v; want the stored order", i, orders[i], errs[i])
+		}
+	}
+
+	// Загруженный заказ попал в кэш
+	if _, err := s.GetInfo("order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := repo.calls.Load(); got != 1 {
+		t.Errorf("GetById called %d times after load, want 1", got)
+	}
+}
+
+func TestGetInfoCoalescesConcurrentErrors(t *testing.T) {
+	const callers = 50
+	errDB := errors.New("connection refused")
+	repo := newStubRepo(testOrder("order-1"))
+	repo.err = errDB
+	s := newTestService(repo)
+
+	orders, errs := getConcurrently(t, s, repo, "order-1", callers)
+
+	if got := repo.calls.Load(); got != 1 {
+		t.Fatalf("GetById called %d times for %d concurrent misses, want 1", got, callers)
+	}
+	for i := range callers {
+		if !errors.Is(errs[i], errDB) || orders[i] != nil {
+			t.Errorf("caller %d got %v, %v; want the shared error", i, orders[i], errs[i])
+		}
+	}
+
+	// Ошибка не кэшируется: следующий вызов снова идёт в БД
+	repo.err = nil
+	order, err := s.GetInfo("order-1")
+	if err != nil || order == nil || order.OrderUID != "order-1" {
+		t.Fatalf("GetInfo after error = %v, %v; want order-1", order, err)
+	}
+	if got := repo.calls.Load(); got != 2 {
+		t.Errorf("GetById called %d times, want 2", got)
+	}
+}
+
+func TestGetInfoNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		get  func(s *OrderService, orderUID string) (*domain.Order, error)
+	}{
+		{name: "cached read", get: (*OrderService).GetInfo},
+		{name: "fresh read", get: (*OrderService).GetInfoFresh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newStubRepo()
+			s := newTestService(repo)
+
+			order, err := tt.get(s, "unknown")
+			if !errors.Is(err, ErrOrderNotFound) || order != nil {
+				t.Fatalf("got %v, %v; want ErrOrderNotFound", order, err)
+			}
+			if !s.isMissing("unknown") {
+				t.Error("unknown UID was not cached as missing")
+			}
+		})
+	}
+}
+
+func TestGetInfoNegativeCache(t *testing.T) {
+	repo := newStubRepo()
+	s := newTestService(repo)
+
+	for range 3 {
+		if _, err := s.GetInfo("order-1"); !errors.Is(err, ErrOrderNotFound) {
+			t.Fatalf("GetInfo error = %v, want ErrOrderNotFound", err)
+		}
+	}
+	if got := repo.calls.Load(); got != 1 {
+		t.Fatalf("GetById called %d times within negative TTL, want 1", got)
+	}
+
+	// Сохранённый заказ сразу снимает негативную запись
+	if _, err := s.Create(testOrder("order-1")); err != nil {
+		t.Fatal(err)
+	}
+	if s.isMissing("order-1") {
+		t.Fatal("Create did not clear the negative entry")
+	}
+	order, err := s.GetInfo("order-1")
+	if err != nil || order.OrderUID != "order-1" {
+		t.Fatalf("GetInfo after Create = %v, %v; want order-1", order, err)
+	}
+}
+
+func TestGetInfoNegativeCacheExpires(t *testing.T) {
+	repo := newStubRepo()
+	s := newTestService(repo)
+
+	s.GetInfo("order-1")
+	age(s.missing, "order-1", time.Second)
+
+	// Заказ появился в БД в обход сервиса, например из другого экземпляра
+	repo.orders["order-1"] = testOrder("order-1")
+	order, err := s.GetInfo("order-1")
+	if err != nil || order.OrderUID != "order-1" {
+		t.Fatalf("GetInfo after negative TTL = %v, %v; want order-1", order, err)
+	}
+	if got := repo.calls.Load(); got != 2 {
+		t.Errorf("GetById called %d times, want 2", got)
+	}
+}
+
+func TestGetInfoNegativeCacheDisabled(t *testing.T) {
+	repo := newStubRepo()
+	s := NewOrderService(repo, &config.CacheConfig{Size: 10}, repository.ConflictReject)
+
+	for range 3 {
+		if _, err := s.GetInfo("order-1"); !errors.Is(err, ErrOrderNotFound) {
+			t.Fatalf("GetInfo error = %v, want ErrOrderNotFound", err)
+		}
+	}
+	if got := repo.calls.Load(); got != 3 {
+		t.Errorf("GetById called %d times without negative cache, want 3", got)
+	}
+}
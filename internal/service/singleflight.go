@@ -0,0 +1,48 @@
+package service
+
+import (
+	"Order-tracker-service/internal/domain"
+	"sync"
+)
+
+// loadCall загрузка заказа, которую ожидают один или несколько вызывающих
+type loadCall struct {
+	wg    sync.WaitGroup
+	order *domain.Order
+	err   error
+}
+
+// loadGroup объединяет параллельные загрузки одного и того же заказа в одну
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+func newLoadGroup() *loadGroup {
+	return &loadGroup{calls: make(map[string]*loadCall)}
+}
+
+// Do выполняет fn для ключа key. Если загрузка по этому ключу уже идёт,
+// вызывающий дожидается её и получает тот же результат; shared=true в этом случае.
+func (g *loadGroup) Do(key string, fn func() (*domain.Order, error)) (order *domain.Order, err error, shared bool) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.order, call.err, true
+	}
+	call := &loadCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	call.order, call.err = fn()
+	return call.order, call.err, false
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// drainTimeout сколько ждать обработки сообщений в работе при паузе консьюмера
+const drainTimeout = 30 * time.Second
+
+// partitionsRequest тело запросов паузы и возобновления.
+// Пустой список партиций означает все партиции.
+type partitionsRequest struct {
+	Partitions []int32 `json:"partitions"`
+}
+
+// requireAdmin пропускает только запросы с заголовком
+// Authorization: Bearer <ADMIN_TOKEN>
+func (h *Handler) requireAdmin(c *gin.Context) {
+	if h.adminToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "Admin API is disabled",
+		})
+		return
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized",
+		})
+		return
+	}
+
+	c.Next()
+}
+
+// bindPartitions читает список партиций из тела запроса; тело необязательно
+func bindPartitions(c *gin.Context) ([]int32, bool) {
+	var req partitionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return nil, false
+	}
+	return req.Partitions, true
+}
+
+// PauseConsumer обрабатывает POST запрос для приостановки потребления.
+// Ответ приходит после того, как обработаны сообщения, уже находящиеся в работе.
+func (h *Handler) PauseConsumer(c *gin.Context) {
+	partitions, ok := bindPartitions(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), drainTimeout)
+	defer cancel()
+
+	if err := h.consumer.Pause(ctx, partitions); err != nil {
+		// Пауза остаётся в силе, но подтвердить её пока нельзя
+		c.JSON(http.StatusGatewayTimeout, gin.H{
+			"error":  err.Error(),
+			"status": h.consumer.Status(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.consumer.Status())
+}
+
+// ResumeConsumer обрабатывает POST запрос для возобновления потребления
+func (h *Handler) ResumeConsumer(c *gin.Context) {
+	partitions, ok := bindPartitions(c)
+	if !ok {
+		return
+	}
+
+	if err := h.consumer.Resume(partitions); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.consumer.Status())
+}
@@ -2,22 +2,39 @@ package http
 
 import (
 	"Order-tracker-service/internal/domain"
+	"Order-tracker-service/internal/repository"
 	"Order-tracker-service/internal/service"
+	"Order-tracker-service/internal/transport/kafka"
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ConsumerControl состояние и управление Kafka консьюмером
+type ConsumerControl interface {
+	Status() kafka.Status
+	Pause(ctx context.Context, partitions []int32) error
+	Resume(partitions []int32) error
+}
+
 // Handler представляет HTTP хэндлер
 type Handler struct {
 	orderService *service.OrderService
+	consumer     ConsumerControl
+	adminToken   string // токен для изменяющих /api/v1/admin, пусто — они выключены
 }
 
 // NewHandler создает новый экземпляр HTTP хэндлера
-func NewHandler(orderService *service.OrderService) *Handler {
+func NewHandler(orderService *service.OrderService, consumer ConsumerControl, adminToken string) *Handler {
 	return &Handler{
 		orderService: orderService,
+		consumer:     consumer,
+		adminToken:   adminToken,
 	}
 }
 
@@ -31,27 +48,43 @@ func (h *Handler) GetOrder(c *gin.Context) {
 		return
 	}
 
-	// Получаем заказ из сервиса
-	order, err := h.orderService.GetInfo(orderUID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get order",
-		})
-		return
+	// Получаем заказ из сервиса; Cache-Control: no-cache читает заказ в обход кэша
+	var order *domain.Order
+	var err error
+	if bypassCache(c) {
+		order, err = h.orderService.GetInfoFresh(orderUID)
+	} else {
+		order, err = h.orderService.GetInfo(orderUID)
 	}
-
-	if order == nil {
+	if errors.Is(err, service.ErrOrderNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Order not found",
 		})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get order",
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"order": order,
 	})
 }
 
+// bypassCache проверяет, просит ли клиент не использовать кэш
+func bypassCache(c *gin.Context) bool {
+	for _, directive := range strings.Split(c.GetHeader("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "no-store", "max-age=0":
+			return true
+		}
+	}
+	return strings.EqualFold(c.GetHeader("Pragma"), "no-cache")
+}
+
 // Index обрабатывает GET запрос для главной страницы
 func (h *Handler) Index(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.html", gin.H{
@@ -60,40 +93,189 @@ func (h *Handler) Index(c *gin.Context) {
 	})
 }
 
-// HealthCheck обрабатывает GET запрос для проверки здоровья сервиса
+// HealthCheck обрабатывает GET запрос для проверки здоровья сервиса.
+// Если консьюмер остановлен или завис, сервис отвечает 503.
 func (h *Handler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
+	consumer := h.consumer.Status()
+
+	status, code := "healthy", http.StatusOK
+	if !consumer.Running || consumer.Stalled {
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, gin.H{
+		"status":  status,
 		"service": "order-tracker-service",
+		"consumer": gin.H{
+			"running": consumer.Running,
+			"stalled": consumer.Stalled,
+			"lag":     consumer.Lag,
+		},
 	})
 }
 
-// GetAllOrders обрабатывает GET запрос для получения всех заказов
-func (h *Handler) GetAllOrders(c *gin.Context) {
-	// Получаем параметры пагинации
-	pageStr := c.DefaultQuery("page", "1")
-	limitStr := c.DefaultQuery("limit", "10")
+// ConsumerStatus обрабатывает GET запрос для получения подробного состояния
+// Kafka консьюмера по партициям
+func (h *Handler) ConsumerStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.consumer.Status())
+}
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
+// Readiness обрабатывает GET запрос для проверки готовности сервиса.
+// Пока кэш прогревается, сервис отвечает 503.
+func (h *Handler) Readiness(c *gin.Context) {
+	status := h.orderService.WarmUpStatus()
+	if !status.Done {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "not ready",
+			"warmup": status,
+		})
+		return
 	}
 
-	limit, err := strconv.Atoi(limitStr)
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ready",
+		"warmup": status,
+	})
+}
+
+// GetAllOrders обрабатывает GET запрос для постраничного получения заказов.
+// Параметры: limit (1..100), cursor (next_cursor предыдущей страницы),
+// with_total=true для подсчёта общего количества заказов.
+func (h *Handler) GetAllOrders(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	if err != nil || limit < 1 || limit > 100 {
 		limit = 10
 	}
 
-	// В реальном приложении здесь была бы логика пагинации
-	// Пока возвращаем простой ответ
+	params := repository.ListParams{Limit: limit}
+	params.WithTotal, _ = strconv.ParseBool(c.Query("with_total"))
+
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursor, err := repository.DecodeCursor(cursorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid cursor",
+			})
+			return
+		}
+		params.After = &cursor
+	}
+
+	page, err := h.orderService.ListOrders(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list orders",
+		})
+		return
+	}
+
+	orders := page.Orders
+	if orders == nil {
+		orders = []*domain.Order{}
+	}
+	response := gin.H{
+		"orders":      orders,
+		"limit":       limit,
+		"next_cursor": nil,
+	}
+	if page.NextCursor != nil {
+		response["next_cursor"] = page.NextCursor.Encode()
+	}
+	if page.Total != nil {
+		response["total"] = *page.Total
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SearchOrders обрабатывает GET запрос для поиска заказов по фильтрам.
+// Фильтры: customer_id, track_number, delivery_service, transaction, rid, nm_id,
+// brand, phone, email, created_from, created_to (RFC3339 или YYYY-MM-DD).
+// Сортировка: sort (date_created, order_uid, customer_id, track_number), order (asc|desc).
+// Пагинация: limit (1..100), offset.
+func (h *Handler) SearchOrders(c *gin.Context) {
+	params := repository.SearchParams{
+		Filter: repository.SearchFilter{
+			CustomerID:      c.Query("customer_id"),
+			TrackNumber:     c.Query("track_number"),
+			DeliveryService: c.Query("delivery_service"),
+			Transaction:     c.Query("transaction"),
+			ItemRID:         c.Query("rid"),
+			Brand:           c.Query("brand"),
+			Phone:           c.Query("phone"),
+			Email:           c.Query("email"),
+		},
+		SortBy: c.DefaultQuery("sort", "date_created"),
+		Desc:   !strings.EqualFold(c.DefaultQuery("order", "desc"), "asc"),
+	}
+
+	var err error
+	if params.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "10")); err != nil || params.Limit < 1 || params.Limit > 100 {
+		params.Limit = 10
+	}
+	if params.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil || params.Offset < 0 {
+		params.Offset = 0
+	}
+
+	if nmID := c.Query("nm_id"); nmID != "" {
+		if params.Filter.NmID, err = strconv.Atoi(nmID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid nm_id",
+			})
+			return
+		}
+	}
+	if params.Filter.CreatedFrom, err = parseTimeQuery(c, "created_from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid created_from",
+		})
+		return
+	}
+	if params.Filter.CreatedTo, err = parseTimeQuery(c, "created_to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid created_to",
+		})
+		return
+	}
+
+	result, err := h.orderService.SearchOrders(params)
+	if errors.Is(err, service.ErrInvalidSearch) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to search orders",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Get all orders endpoint",
-		"page":    page,
-		"limit":   limit,
-		"orders":  []domain.Order{},
+		"orders": result.Orders,
+		"total":  result.Total,
+		"limit":  params.Limit,
+		"offset": params.Offset,
 	})
 }
 
+// parseTimeQuery разбирает query-параметр в формате RFC3339 или YYYY-MM-DD
+func parseTimeQuery(c *gin.Context, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 // InitRoutes инициализирует маршруты
 func (h *Handler) InitRoutes() *gin.Engine {
 	// Создаем Gin роутер
@@ -103,7 +285,7 @@ func (h *Handler) InitRoutes() *gin.Engine {
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Cache-Control")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -128,10 +310,21 @@ func (h *Handler) InitRoutes() *gin.Engine {
 	{
 		// Health check
 		api.GET("/health", h.HealthCheck)
+		api.GET("/ready", h.Readiness)
 
 		// Заказы
 		api.GET("/orders", h.GetAllOrders)
+		api.GET("/orders/search", h.SearchOrders)
 		api.GET("/orders/:id", h.GetOrder)
+
+		// Состояние консьюмера только читается и доступно без токена,
+		// как и проверка здоровья
+		api.GET("/admin/consumer", h.ConsumerStatus)
+
+		// Администрирование
+		admin := api.Group("/admin", h.requireAdmin)
+		admin.POST("/consumer/pause", h.PauseConsumer)
+		admin.POST("/consumer/resume", h.ResumeConsumer)
 	}
 
 	// Главная страница
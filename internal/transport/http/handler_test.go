@@ -0,0 +1,90 @@
+package http
+
+import (
+	"Order-tracker-service/config"
+	"Order-tracker-service/internal/domain"
+	"Order-tracker-service/internal/repository"
+	"Order-tracker-service/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubRepo отдаёт заказы из памяти или ошибку err
+type stubRepo struct {
+	repository.OrderRepository
+	orders map[string]*domain.Order
+	err    error
+}
+
+func (r *stubRepo) GetById(orderUID string) (*domain.Order, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	order, ok := r.orders[orderUID]
+	if !ok {
+		return nil, repository.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func TestGetOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		repoErr  error
+		uid      string
+		noCache  bool
+		wantCode int
+		wantUID  string
+	}{
+		{name: "found", uid: "order-1", wantCode: http.StatusOK, wantUID: "order-1"},
+		{name: "found bypassing cache", uid: "order-1", noCache: true, wantCode: http.StatusOK, wantUID: "order-1"},
+		{name: "unknown", uid: "unknown", wantCode: http.StatusNotFound},
+		{name: "unknown bypassing cache", uid: "unknown", noCache: true, wantCode: http.StatusNotFound},
+		{name: "database error", repoErr: errors.New("connection refused"), uid: "order-1", wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &stubRepo{
+				orders: map[string]*domain.Order{"order-1": {OrderUID: "order-1"}},
+				err:    tt.repoErr,
+			}
+			orders := service.NewOrderService(repo, &config.CacheConfig{Size: 10, NegativeTTL: time.Minute}, repository.ConflictReject)
+			h := NewHandler(orders, nil, "")
+
+			r := gin.New()
+			r.GET("/api/v1/orders/:id", h.GetOrder)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+tt.uid, nil)
+			if tt.noCache {
+				req.Header.Set("Cache-Control", "no-cache")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantUID == "" {
+				return
+			}
+			var body struct {
+				Order *domain.Order `json:"order"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Order == nil || body.Order.OrderUID != tt.wantUID {
+				t.Errorf("order = %+v, want %s", body.Order, tt.wantUID)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"Order-tracker-service/internal/domain"
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// consumeBatches собирает сообщения партиции в пачки по размеру или времени
+// ожидания и сохраняет каждую пачку одним вызовом HandleOrders. Offset
+// фиксируется один раз на пачку.
+func (c *Consumer) consumeBatches(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim, handler BatchMessageHandler) error {
+	batch := make([]*sarama.ConsumerMessage, 0, c.config.BatchSize)
+
+	linger := time.NewTimer(c.config.BatchLinger)
+	linger.Stop()
+	defer linger.Stop()
+
+	flush := func() bool {
+		defer func() { batch = batch[:0] }()
+		linger.Stop()
+		if len(batch) == 0 {
+			return true
+		}
+		if !c.pause.acquire(session.Context(), claim.Partition()) {
+			return false
+		}
+		defer c.pause.release(claim.Partition())
+		return c.handleBatch(session, batch, handler)
+	}
+
+	for {
+		select {
+		case message := <-claim.Messages():
+			if message == nil {
+				flush()
+				return nil
+			}
+			c.stats.received(claim)
+
+			if len(batch) == 0 {
+				linger.Reset(c.config.BatchLinger)
+			}
+			batch = append(batch, message)
+			if len(batch) >= c.config.BatchSize && !flush() {
+				return nil
+			}
+
+		case <-linger.C:
+			if !flush() {
+				return nil
+			}
+
+		case <-session.Context().Done():
+			// Неполная пачка не подтверждена и будет получена заново
+			return nil
+		}
+	}
+}
+
+// handleBatch обрабатывает пачку сообщений. Невалидные сообщения сразу уходят
+// в dead-letter топик. Если пачку не удалось сохранить целиком, сообщения
+// обрабатываются по одному, чтобы отделить проблемный заказ от остальных.
+// Возвращает false, если обработку прервало завершение сессии.
+func (c *Consumer) handleBatch(session sarama.ConsumerGroupSession, batch []*sarama.ConsumerMessage, handler BatchMessageHandler) bool {
+	ctx := session.Context()
+	last := batch[len(batch)-1]
+
+	if c.guarantee == AtMostOnce {
+		commitMessage(session, last)
+	}
+
+	log.Printf("Received batch of %d messages from topic %s, partition %d, offsets %d-%d",
+		len(batch), last.Topic, last.Partition, batch[0].Offset, last.Offset)
+
+	orders := make([]*domain.Order, 0, len(batch))
+	valid := make([]*sarama.ConsumerMessage, 0, len(batch))
+	for _, message := range batch {
+		order, err := c.decodeMessage(message)
+		if err != nil {
+			c.stats.failed(message, err)
+			if err := c.deadLetter(ctx, message, err, 1); err != nil {
+				return false
+			}
+			continue
+		}
+		orders = append(orders, order)
+		valid = append(valid, message)
+	}
+
+	if len(orders) > 0 {
+		attempts, err := c.retry.do(ctx, func() error {
+			err := handler.HandleOrders(ctx, orders)
+			if err != nil && ctx.Err() == nil {
+				c.stats.failed(last, err)
+			}
+			return err
+		})
+		if err != nil && ctx.Err() != nil {
+			log.Printf("Processing of batch interrupted by session end: %v", err)
+			return false
+		}
+		if err != nil {
+			log.Printf("Error processing batch after %d attempt(s), falling back to one-by-one: %v", attempts, err)
+			for _, message := range valid {
+				if !c.handleMessage(ctx, message) {
+					return false
+				}
+			}
+		} else {
+			for _, message := range valid {
+				c.stats.processed(message)
+			}
+		}
+	}
+
+	c.stats.advance(last)
+	if c.guarantee == AtLeastOnce {
+		markMessage(session, last)
+	}
+	return true
+}
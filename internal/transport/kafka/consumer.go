@@ -4,9 +4,9 @@ import (
 	"Order-tracker-service/config"
 	"Order-tracker-service/internal/domain"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"sync"
 	"time"
 
@@ -16,8 +16,16 @@ import (
 // Consumer представляет Kafka консьюмер
 type Consumer struct {
 	config    *config.KafkaConfig
+	client    sarama.Client // нужен консьюмеру для запросов offset'ов у брокера
 	consumer  sarama.ConsumerGroup
 	handler   MessageHandler
+	decoders  *DecoderRegistry
+	dlq       *DeadLetterQueue // nil, если dead-letter топик не настроен
+	retry     retryPolicy
+	guarantee DeliveryGuarantee
+	stats     *consumerStats
+	pause     *pauseGate
+	startedAt time.Time
 	ctx       context.Context
 	cancel    context.CancelFunc
 	wg        sync.WaitGroup
@@ -25,36 +33,79 @@ type Consumer struct {
 	mu        sync.RWMutex
 }
 
-// MessageHandler интерфейс для обработки сообщений
+// MessageHandler интерфейс для обработки сообщений.
+// Ошибки, которые не имеет смысла повторять, обработчик помечает
+// типом с методом Permanent() bool; остальные считаются временными.
 type MessageHandler interface {
 	HandleOrder(ctx context.Context, order *domain.Order) error
 }
 
+// BatchMessageHandler обработчик, умеющий сохранять заказы пачками.
+// Если handler его реализует и KafkaConfig.BatchSize > 1, консьюмер
+// собирает сообщения в пачки.
+type BatchMessageHandler interface {
+	MessageHandler
+	HandleOrders(ctx context.Context, orders []*domain.Order) error
+}
+
 // NewConsumer создает новый экземпляр консьюмера
 func NewConsumer(cfg *config.KafkaConfig, handler MessageHandler) (*Consumer, error) {
+	guarantee, err := ParseDeliveryGuarantee(cfg.DeliveryGuarantee)
+	if err != nil {
+		return nil, err
+	}
+
 	// Настройка конфигурации Sarama
-	saramaConfig := sarama.NewConfig()
-	saramaConfig.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
-	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetNewest
-	saramaConfig.Consumer.Group.Session.Timeout = 10 * time.Second
-	saramaConfig.Consumer.Group.Heartbeat.Interval = 3 * time.Second
-	saramaConfig.Consumer.MaxProcessingTime = 500 * time.Millisecond
-	saramaConfig.Consumer.Return.Errors = true
+	saramaConfig, err := newConsumerConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	decoders, err := NewDecoderRegistry(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Создание консьюмера
-	consumer, err := sarama.NewConsumerGroup(cfg.Brokers, "order-tracker-group", saramaConfig)
+	client, err := sarama.NewClient(cfg.Brokers, saramaConfig)
 	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	consumer, err := sarama.NewConsumerGroupFromClient(cfg.GroupID, client)
+	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to create consumer group: %w", err)
 	}
 
+	// Dead-letter топик для сообщений, которые не удалось обработать
+	var dlq *DeadLetterQueue
+	if cfg.DLQTopic != "" {
+		if dlq, err = NewDeadLetterQueue(cfg); err != nil {
+			consumer.Close()
+			client.Close()
+			return nil, err
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &Consumer{
 		config:   cfg,
+		client:   client,
 		consumer: consumer,
 		handler:  handler,
-		ctx:      ctx,
-		cancel:   cancel,
+		decoders: decoders,
+		dlq:      dlq,
+		retry: retryPolicy{
+			maxRetries: cfg.RetryMax,
+			baseDelay:  cfg.RetryBackoff,
+			maxDelay:   cfg.RetryMaxBackoff,
+		},
+		guarantee: guarantee,
+		stats:     newConsumerStats(),
+		pause:     newPauseGate(),
+		ctx:       ctx,
+		cancel:    cancel,
 	}, nil
 }
 
@@ -67,13 +118,18 @@ func (c *Consumer) Start() error {
 		return fmt.Errorf("consumer is already running")
 	}
 
-	c.wg.Add(1)
+	c.wg.Add(2)
 	go func() {
 		defer c.wg.Done()
 		c.consume()
 	}()
+	go func() {
+		defer c.wg.Done()
+		c.refreshHighWaterMarks()
+	}()
 
 	c.isRunning = true
+	c.startedAt = time.Now()
 	log.Printf("Kafka consumer started, listening to topic: %s", c.config.Topic)
 	return nil
 }
@@ -93,6 +149,15 @@ func (c *Consumer) Stop() error {
 	if err := c.consumer.Close(); err != nil {
 		return fmt.Errorf("failed to close consumer: %w", err)
 	}
+	if err := c.client.Close(); err != nil {
+		return fmt.Errorf("failed to close kafka client: %w", err)
+	}
+
+	if c.dlq != nil {
+		if err := c.dlq.Close(); err != nil {
+			return err
+		}
+	}
 
 	c.isRunning = false
 	log.Println("Kafka consumer stopped")
@@ -118,34 +183,64 @@ func (c *Consumer) consume() {
 }
 
 // Setup вызывается в начале новой сессии
-func (c *Consumer) Setup(sarama.ConsumerGroupSession) error {
-	log.Println("Kafka consumer session started")
+func (c *Consumer) Setup(session sarama.ConsumerGroupSession) error {
+	c.stats.assign(session.Claims())
+	log.Printf("Kafka consumer session started, claims: %v", session.Claims())
 	return nil
 }
 
 // Cleanup вызывается в конце сессии
 func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
+	c.pause.reset()
 	log.Println("Kafka consumer session ended")
 	return nil
 }
 
 // ConsumeClaim обрабатывает сообщения из партиции
 func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	// Пауза Sarama действует только на партиции текущей сессии, поэтому
+	// после ребалансировки её нужно применить заново
+	if c.pause.paused(claim.Partition()) {
+		c.consumer.Pause(map[string][]int32{claim.Topic(): {claim.Partition()}})
+	}
+	c.startClaim(claim)
+
+	if batchHandler, ok := c.handler.(BatchMessageHandler); ok && c.config.BatchSize > 1 {
+		return c.consumeBatches(session, claim, batchHandler)
+	}
+	if c.config.Workers > 1 {
+		return c.consumeParallel(session, claim)
+	}
+
 	for {
 		select {
 		case message := <-claim.Messages():
 			if message == nil {
 				return nil
 			}
+			c.stats.received(claim)
+
+			if !c.pause.acquire(session.Context(), message.Partition) {
+				return nil
+			}
+
+			if c.guarantee == AtMostOnce {
+				commitMessage(session, message)
+			}
 
-			// Обработка сообщения
-			if err := c.processMessage(message); err != nil {
-				log.Printf("Error processing message: %v", err)
-				// В реальном приложении здесь может быть логика retry или dead letter queue
+			if !c.handleMessage(session.Context(), message) || session.Context().Err() != nil {
+				// Сессия завершилась во время обработки (ребалансировка или
+				// остановка): сообщение не подтверждаем, как и в параллельной
+				// обработке, его заново получит следующий владелец партиции
+				c.pause.release(message.Partition)
+				return nil
 			}
 
-			// Подтверждение обработки сообщения
-			session.MarkMessage(message, "")
+			c.stats.advance(message)
+			if c.guarantee == AtLeastOnce {
+				markMessage(session, message)
+			}
+			c.pause.release(message.Partition)
 
 		case <-session.Context().Done():
 			return nil
@@ -153,24 +248,82 @@ func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim saram
 	}
 }
 
-// processMessage обрабатывает отдельное сообщение
-func (c *Consumer) processMessage(message *sarama.ConsumerMessage) error {
-	log.Printf("Received message from topic %s, partition %d, offset %d",
-		message.Topic, message.Partition, message.Offset)
+// handleMessage обрабатывает сообщение с повторами временных ошибок, а
+// необработанное отправляет в dead-letter топик. Возвращает false, если
+// обработку прервало завершение сессии и сообщение не подтверждено.
+func (c *Consumer) handleMessage(ctx context.Context, message *sarama.ConsumerMessage) bool {
+	attempts, err := c.retry.do(ctx, func() error {
+		err := c.processMessage(ctx, message)
+		if err != nil && ctx.Err() == nil {
+			c.stats.failed(message, err)
+		}
+		return err
+	})
+	if err == nil {
+		c.stats.processed(message)
+		return true
+	}
+	if ctx.Err() != nil {
+		log.Printf("Processing of offset %d interrupted by session end: %v", message.Offset, err)
+		return false
+	}
 
-	// Десериализация сообщения в структуру Order
-	var order domain.Order
-	if err := json.Unmarshal(message.Value, &order); err != nil {
-		return fmt.Errorf("failed to unmarshal message: %w", err)
+	log.Printf("Error processing message after %d attempt(s): %v", attempts, err)
+	if err := c.deadLetter(ctx, message, err, attempts); err != nil {
+		log.Printf("Dead-lettering of offset %d interrupted by session end: %v", message.Offset, err)
+		return false
 	}
+	return true
+}
 
-	// Валидация заказа
-	if order.OrderUID == "" {
-		return fmt.Errorf("invalid order: missing OrderUID")
+// deadLetter отправляет сообщение в dead-letter топик. В режиме at-least-once
+// отправка повторяется, пока не удастся или не завершится сессия: offset нельзя
+// фиксировать, пока сообщение никуда не передано. Если топик не настроен или
+// гарантия at-most-once, после неудачи сообщение целиком пишется в лог.
+func (c *Consumer) deadLetter(ctx context.Context, message *sarama.ConsumerMessage, reason error, attempts int) error {
+	if c.dlq != nil {
+		publish := func() error {
+			err := c.dlq.Publish(message, reason, attempts)
+			if err != nil {
+				log.Printf("Error publishing to dead-letter topic: %v", err)
+			}
+			return err
+		}
+
+		var err error
+		if c.guarantee == AtLeastOnce {
+			policy := c.retry
+			policy.maxRetries = math.MaxInt
+			_, err = policy.do(ctx, publish)
+		} else {
+			err = publish()
+		}
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+
+	log.Printf("Dropping message from topic %s, partition %d, offset %d after %d attempt(s): %v; key=%q value=%q",
+		message.Topic, message.Partition, message.Offset, attempts, reason, message.Key, message.Value)
+	return nil
+}
+
+// processMessage обрабатывает отдельное сообщение. Ошибки, повтор которых
+// не поможет, помечаются как постоянные.
+func (c *Consumer) processMessage(ctx context.Context, message *sarama.ConsumerMessage) error {
+	log.Printf("Received message from topic %s, partition %d, offset %d",
+		message.Topic, message.Partition, message.Offset)
+
+	order, err := c.decodeMessage(message)
+	if err != nil {
+		return err
 	}
 
 	// Обработка заказа через handler
-	if err := c.handler.HandleOrder(c.ctx, &order); err != nil {
+	if err := c.handler.HandleOrder(ctx, order); err != nil {
 		return fmt.Errorf("failed to handle order %s: %w", order.OrderUID, err)
 	}
 
@@ -178,6 +331,61 @@ func (c *Consumer) processMessage(message *sarama.ConsumerMessage) error {
 	return nil
 }
 
+// decodeMessage десериализует заказ декодером, выбранным по заголовкам
+// сообщения, и валидирует его. Ошибки декодирования постоянные.
+func (c *Consumer) decodeMessage(message *sarama.ConsumerMessage) (*domain.Order, error) {
+	order, err := c.decoders.Decode(message)
+	if err != nil {
+		return nil, err
+	}
+
+	// Валидация заказа
+	if order.OrderUID == "" {
+		return nil, permanent(fmt.Errorf("invalid order: missing OrderUID"))
+	}
+
+	return order, nil
+}
+
+// Status возвращает состояние консьюмера по назначенным партициям.
+// Консьюмер считается зависшим, если у партиции есть отставание, а успешной
+// обработки не было дольше KafkaConfig.StallTimeout.
+func (c *Consumer) Status() Status {
+	c.mu.RLock()
+	running, startedAt := c.isRunning, c.startedAt
+	c.mu.RUnlock()
+
+	status := Status{
+		Running:    running,
+		GroupID:    c.config.GroupID,
+		Topic:      c.config.Topic,
+		Partitions: c.stats.snapshot(),
+	}
+
+	for i := range status.Partitions {
+		p := &status.Partitions[i]
+		p.Paused = c.pause.paused(p.Partition)
+		if !p.Assigned {
+			continue
+		}
+		status.Lag += p.Lag
+		if p.Paused {
+			// Отставание приостановленной партиции растёт ожидаемо
+			continue
+		}
+
+		lastProgress := startedAt
+		if p.LastSuccessAt != nil {
+			lastProgress = *p.LastSuccessAt
+		}
+		if p.Lag > 0 && time.Since(lastProgress) > c.config.StallTimeout {
+			status.Stalled = true
+		}
+	}
+
+	return status
+}
+
 // IsRunning возвращает статус консьюмера
 func (c *Consumer) IsRunning() bool {
 	c.mu.RLock()
@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"Order-tracker-service/config"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// newConsumerConfig собирает настройки Sarama для группы консьюмеров
+// и проверяет их согласованность, чтобы ошибка конфигурации была видна при старте
+func newConsumerConfig(cfg *config.KafkaConfig) (*sarama.Config, error) {
+	if cfg.GroupID == "" {
+		return nil, errors.New("kafka config: group id is required")
+	}
+	if cfg.BatchSize > 1 && cfg.Workers > 1 {
+		return nil, fmt.Errorf("kafka config: batch size %d and workers %d cannot be combined, choose one", cfg.BatchSize, cfg.Workers)
+	}
+	if cfg.HeartbeatInterval >= cfg.SessionTimeout {
+		return nil, fmt.Errorf("kafka config: heartbeat interval %v must be lower than session timeout %v",
+			cfg.HeartbeatInterval, cfg.SessionTimeout)
+	}
+
+	if cfg.CommitInterval <= 0 {
+		return nil, fmt.Errorf("kafka config: commit interval must be positive, got %v", cfg.CommitInterval)
+	}
+
+	saramaConfig := sarama.NewConfig()
+
+	switch strings.ToLower(cfg.InitialOffset) {
+	case "earliest", "oldest":
+		saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	case "latest", "newest":
+		saramaConfig.Consumer.Offsets.Initial = sarama.OffsetNewest
+	default:
+		return nil, fmt.Errorf("kafka config: unknown initial offset %q: expected earliest or latest", cfg.InitialOffset)
+	}
+
+	strategies, err := parseRebalanceStrategies(cfg.RebalanceStrategy)
+	if err != nil {
+		return nil, err
+	}
+	saramaConfig.Consumer.Group.Rebalance.GroupStrategies = strategies
+
+	saramaConfig.Consumer.Group.Session.Timeout = cfg.SessionTimeout
+	saramaConfig.Consumer.Group.Heartbeat.Interval = cfg.HeartbeatInterval
+	saramaConfig.Consumer.Group.Rebalance.Timeout = cfg.RebalanceTimeout
+	saramaConfig.Consumer.MaxProcessingTime = cfg.MaxProcessingTime
+	saramaConfig.Consumer.Return.Errors = true
+	// Консьюмер помечает offset'ы в момент, определяемый режимом доставки,
+	// а Sarama фиксирует помеченные раз в CommitInterval и при завершении сессии
+	saramaConfig.Consumer.Offsets.AutoCommit.Enable = true
+	saramaConfig.Consumer.Offsets.AutoCommit.Interval = cfg.CommitInterval
+
+	if err := applySecurity(saramaConfig, cfg); err != nil {
+		return nil, err
+	}
+
+	if err := saramaConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("kafka config: %w", err)
+	}
+	return saramaConfig, nil
+}
+
+// parseRebalanceStrategies переводит названия стратегий ребалансировки в
+// стратегии Sarama. Несколько стратегий перечисляются в порядке предпочтения,
+// что позволяет сменить стратегию без остановки всей группы.
+func parseRebalanceStrategies(names []string) ([]sarama.BalanceStrategy, error) {
+	if len(names) == 0 {
+		return nil, errors.New("kafka config: at least one rebalance strategy is required")
+	}
+
+	strategies := make([]sarama.BalanceStrategy, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "range":
+			strategies = append(strategies, sarama.NewBalanceStrategyRange())
+		case "roundrobin", "round-robin":
+			strategies = append(strategies, sarama.NewBalanceStrategyRoundRobin())
+		case "sticky":
+			strategies = append(strategies, sarama.NewBalanceStrategySticky())
+		case "cooperative-sticky", "cooperative":
+			// Sarama реализует только eager-протокол ребалансировки
+			return nil, fmt.Errorf("kafka config: rebalance strategy %q is not supported by the Kafka client, use sticky", name)
+		default:
+			return nil, fmt.Errorf("kafka config: unknown rebalance strategy %q: expected range, roundrobin or sticky", name)
+		}
+	}
+	return strategies, nil
+}
@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"Order-tracker-service/config"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Заголовки, которые DeadLetterQueue добавляет к сообщению
+const (
+	HeaderDLQReason            = "x-dlq-reason"
+	HeaderDLQOriginalTopic     = "x-dlq-original-topic"
+	HeaderDLQOriginalPartition = "x-dlq-original-partition"
+	HeaderDLQOriginalOffset    = "x-dlq-original-offset"
+	HeaderDLQAttempts          = "x-dlq-attempts"
+	HeaderDLQFailedAt          = "x-dlq-failed-at"
+)
+
+// DeadLetterQueue публикует сообщения, которые не удалось обработать,
+// в отдельный топик вместе с причиной ошибки и координатами оригинала
+type DeadLetterQueue struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+// NewDeadLetterQueue создает producer для dead-letter топика
+func NewDeadLetterQueue(cfg *config.KafkaConfig) (*DeadLetterQueue, error) {
+	saramaConfig, err := newProducerConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter producer: %w", err)
+	}
+
+	return &DeadLetterQueue{
+		producer: producer,
+		topic:    cfg.DLQTopic,
+	}, nil
+}
+
+// Publish отправляет исходное сообщение в dead-letter топик. Ключ, значение
+// и заголовки оригинала сохраняются, к ним добавляются служебные заголовки.
+func (q *DeadLetterQueue) Publish(message *sarama.ConsumerMessage, reason error, attempts int) error {
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers)+6)
+	for _, h := range message.Headers {
+		if h != nil {
+			headers = append(headers, *h)
+		}
+	}
+	headers = append(headers,
+		stringHeader(HeaderDLQReason, reason.Error()),
+		stringHeader(HeaderDLQOriginalTopic, message.Topic),
+		stringHeader(HeaderDLQOriginalPartition, strconv.Itoa(int(message.Partition))),
+		stringHeader(HeaderDLQOriginalOffset, strconv.FormatInt(message.Offset, 10)),
+		stringHeader(HeaderDLQAttempts, strconv.Itoa(attempts)),
+		stringHeader(HeaderDLQFailedAt, time.Now().UTC().Format(time.RFC3339Nano)),
+	)
+
+	msg := &sarama.ProducerMessage{
+		Topic:   q.topic,
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	}
+	if message.Key != nil {
+		msg.Key = sarama.ByteEncoder(message.Key)
+	}
+
+	partition, offset, err := q.producer.SendMessage(msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish to dead-letter topic %s: %w", q.topic, err)
+	}
+
+	log.Printf("Message from %s/%d@%d moved to dead-letter topic %s, partition %d, offset %d: %v",
+		message.Topic, message.Partition, message.Offset, q.topic, partition, offset, reason)
+	return nil
+}
+
+// Close закрывает producer dead-letter топика
+func (q *DeadLetterQueue) Close() error {
+	if err := q.producer.Close(); err != nil {
+		return fmt.Errorf("failed to close dead-letter producer: %w", err)
+	}
+	return nil
+}
+
+func stringHeader(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
@@ -0,0 +1,140 @@
+package kafka
+
+import (
+	"Order-tracker-service/config"
+	"Order-tracker-service/internal/domain"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// Заголовки, по которым выбирается декодер сообщения
+const (
+	HeaderContentType   = "content-type"
+	HeaderSchemaVersion = "schema-version"
+)
+
+// Форматы сообщений с заказами
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/avro"
+)
+
+// Версии схемы заказа. Сообщения без заголовка schema-version
+// считаются сообщениями текущей версии. Пока существует только одна
+// версия — та, что описана domain.Order, orderpb и схемой Avro.
+const (
+	MinSchemaVersion     = 1
+	CurrentSchemaVersion = 1
+)
+
+// contentTypeAliases другие распространённые названия тех же форматов
+var contentTypeAliases = map[string]string{
+	"application/protobuf": ContentTypeProtobuf,
+	"application/x-proto":  ContentTypeProtobuf,
+	"avro/binary":          ContentTypeAvro,
+	"application/x-avro":   ContentTypeAvro,
+}
+
+// Decoder десериализует заказ из значения сообщения одного формата.
+// Поля, которых нет в схеме сообщения, остаются нулевыми: их заполняет
+// переход к текущей версии схемы.
+type Decoder interface {
+	Decode(data []byte) (*domain.Order, error)
+}
+
+// schemaUpgrades переводят заказ из версии i в версию i+1. Переход
+// добавляется вместе с новой версией схемы по её контракту с поставщиком
+// заказов, пока старших версий нет и переходов тоже.
+var schemaUpgrades = map[int]func(*domain.Order){}
+
+// DecoderRegistry выбирает декодер по заголовку content-type и приводит
+// заказ к текущей версии схемы по заголовку schema-version
+type DecoderRegistry struct {
+	decoders map[string]Decoder
+}
+
+// NewDecoderRegistry создает реестр с декодерами JSON и Protobuf. Декодер
+// Avro регистрируется, если в конфигурации указан файл схемы.
+func NewDecoderRegistry(cfg *config.KafkaConfig) (*DecoderRegistry, error) {
+	r := &DecoderRegistry{decoders: make(map[string]Decoder)}
+	r.Register(ContentTypeJSON, jsonDecoder{})
+	r.Register(ContentTypeProtobuf, protobufDecoder{})
+
+	if cfg.AvroSchemaFile != "" {
+		decoder, err := newAvroDecoder(cfg.AvroSchemaFile)
+		if err != nil {
+			return nil, err
+		}
+		r.Register(ContentTypeAvro, decoder)
+	}
+
+	return r, nil
+}
+
+// Register регистрирует декодер формата, заменяя существующий
+func (r *DecoderRegistry) Register(contentType string, decoder Decoder) {
+	r.decoders[contentType] = decoder
+}
+
+// Decode десериализует заказ из сообщения. Ошибки декодирования постоянные:
+// повтор того же сообщения их не исправит.
+func (r *DecoderRegistry) Decode(message *sarama.ConsumerMessage) (*domain.Order, error) {
+	contentType, version, err := messageSchema(message)
+	if err != nil {
+		return nil, permanent(err)
+	}
+
+	decoder, ok := r.decoders[contentType]
+	if !ok {
+		return nil, permanent(fmt.Errorf("unsupported content type %q", contentType))
+	}
+
+	order, err := decoder.Decode(message.Value)
+	if err != nil {
+		return nil, permanent(fmt.Errorf("failed to decode %s message (schema version %d): %w", contentType, version, err))
+	}
+
+	for v := version; v < CurrentSchemaVersion; v++ {
+		schemaUpgrades[v](order)
+	}
+	return order, nil
+}
+
+// messageSchema читает формат и версию схемы из заголовков сообщения
+func messageSchema(message *sarama.ConsumerMessage) (string, int, error) {
+	contentType, version := ContentTypeJSON, CurrentSchemaVersion
+
+	for _, h := range message.Headers {
+		if h == nil {
+			continue
+		}
+		value := strings.TrimSpace(string(h.Value))
+
+		switch strings.ToLower(string(h.Key)) {
+		case HeaderContentType:
+			mediaType, _, err := mime.ParseMediaType(value)
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid %s header %q: %w", HeaderContentType, value, err)
+			}
+			contentType = mediaType
+			if alias, ok := contentTypeAliases[mediaType]; ok {
+				contentType = alias
+			}
+
+		case HeaderSchemaVersion:
+			v, err := strconv.Atoi(strings.TrimPrefix(value, "v"))
+			if err != nil || v < MinSchemaVersion || v > CurrentSchemaVersion {
+				return "", 0, fmt.Errorf("unsupported schema version %q, supported %d-%d",
+					value, MinSchemaVersion, CurrentSchemaVersion)
+			}
+			version = v
+		}
+	}
+
+	return contentType, version, nil
+}
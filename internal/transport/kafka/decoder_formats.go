@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"Order-tracker-service/internal/domain"
+	"Order-tracker-service/internal/transport/kafka/orderpb"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+// jsonDecoder декодирует заказ в формате JSON
+type jsonDecoder struct{}
+
+func (jsonDecoder) Decode(data []byte) (*domain.Order, error) {
+	var order domain.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// protobufDecoder декодирует заказ в формате Protobuf (api/proto/order.proto)
+type protobufDecoder struct{}
+
+func (protobufDecoder) Decode(data []byte) (*domain.Order, error) {
+	var msg orderpb.Order
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	order := &domain.Order{
+		OrderUID:          msg.GetOrderUid(),
+		TrackNumber:       msg.GetTrackNumber(),
+		Entry:             msg.GetEntry(),
+		Locale:            msg.GetLocale(),
+		InternalSignature: msg.GetInternalSignature(),
+		CustomerID:        msg.GetCustomerId(),
+		DeliveryService:   msg.GetDeliveryService(),
+		ShardKey:          msg.GetShardkey(),
+		SmID:              int(msg.GetSmId()),
+		OofShard:          msg.GetOofShard(),
+		Items:             make([]domain.Item, 0, len(msg.GetItems())),
+	}
+	if msg.GetDateCreated() != nil {
+		order.DateCreated = msg.GetDateCreated().AsTime()
+	}
+
+	if d := msg.GetDelivery(); d != nil {
+		order.Delivery = domain.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		}
+	}
+
+	if p := msg.GetPayment(); p != nil {
+		order.Payment = domain.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       int(p.GetAmount()),
+			PaymentDT:    p.GetPaymentDt(),
+			Bank:         p.GetBank(),
+			DeliveryCost: int(p.GetDeliveryCost()),
+			GoodsTotal:   int(p.GetGoodsTotal()),
+			CustomFee:    int(p.GetCustomFee()),
+		}
+	}
+
+	for _, item := range msg.GetItems() {
+		order.Items = append(order.Items, domain.Item{
+			ChrtID:      int(item.GetChrtId()),
+			TrackNumber: item.GetTrackNumber(),
+			Price:       int(item.GetPrice()),
+			RID:         item.GetRid(),
+			Name:        item.GetName(),
+			Sale:        int(item.GetSale()),
+			Size:        item.GetSize(),
+			TotalPrice:  int(item.GetTotalPrice()),
+			NmID:        int(item.GetNmId()),
+			Brand:       item.GetBrand(),
+			Status:      int(item.GetStatus()),
+		})
+	}
+
+	return order, nil
+}
+
+// avroAPI сопоставляет поля записи Avro с полями domain.Order
+// по json тегам, чтобы схема Avro использовала те же имена, что и JSON
+var avroAPI = avro.Config{TagKey: "json"}.Freeze()
+
+// avroDecoder декодирует заказ в бинарном формате Avro по схеме из файла.
+// date_created в схеме — long с логическим типом timestamp-millis или
+// timestamp-micros.
+type avroDecoder struct {
+	schema avro.Schema
+}
+
+func newAvroDecoder(schemaFile string) (*avroDecoder, error) {
+	schema, err := avro.ParseFiles(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load avro schema %s: %w", schemaFile, err)
+	}
+	return &avroDecoder{schema: schema}, nil
+}
+
+func (d *avroDecoder) Decode(data []byte) (*domain.Order, error) {
+	var order domain.Order
+	if err := avroAPI.Unmarshal(d.schema, data, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,307 @@
+package kafka
+
+import (
+	"Order-tracker-service/config"
+	"Order-tracker-service/internal/domain"
+	"Order-tracker-service/internal/transport/kafka/orderpb"
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testAvroSchema пример схемы Avro из репозитория
+const testAvroSchema = "../../../api/avro/order.avsc"
+
+// decoderTestOrder заказ со всеми заполненными полями и двумя товарами.
+// Время с точностью до миллисекунд, как в схеме Avro.
+func decoderTestOrder() *domain.Order {
+	order := domain.GetTestOrder()
+	order.DateCreated = time.Date(2021, 11, 26, 6, 22, 19, 123_000_000, time.UTC)
+	order.Payment.RequestID = "request-1"
+	second := order.Items[0]
+	second.ChrtID, second.RID, second.Name, second.TotalPrice = 42, "rid-2", "Shampoo", 0
+	order.Items = append(order.Items, second)
+	return order
+}
+
+// orderProto кодирует заказ в Protobuf по api/proto/order.proto
+func orderProto(t *testing.T, order *domain.Order) []byte {
+	t.Helper()
+	msg := &orderpb.Order{
+		OrderUid:          order.OrderUID,
+		TrackNumber:       order.TrackNumber,
+		Entry:             order.Entry,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerId:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.ShardKey,
+		SmId:              int64(order.SmID),
+		DateCreated:       timestamppb.New(order.DateCreated),
+		OofShard:          order.OofShard,
+		Delivery: &orderpb.Delivery{
+			Name:    order.Delivery.Name,
+			Phone:   order.Delivery.Phone,
+			Zip:     order.Delivery.Zip,
+			City:    order.Delivery.City,
+			Address: order.Delivery.Address,
+			Region:  order.Delivery.Region,
+			Email:   order.Delivery.Email,
+		},
+		Payment: &orderpb.Payment{
+			Transaction:  order.Payment.Transaction,
+			RequestId:    order.Payment.RequestID,
+			Currency:     order.Payment.Currency,
+			Provider:     order.Payment.Provider,
+			Amount:       int64(order.Payment.Amount),
+			PaymentDt:    order.Payment.PaymentDT,
+			Bank:         order.Payment.Bank,
+			DeliveryCost: int64(order.Payment.DeliveryCost),
+			GoodsTotal:   int64(order.Payment.GoodsTotal),
+			CustomFee:    int64(order.Payment.CustomFee),
+		},
+	}
+	for _, item := range order.Items {
+		msg.Items = append(msg.Items, &orderpb.Item{
+			ChrtId:      int64(item.ChrtID),
+			TrackNumber: item.TrackNumber,
+			Price:       int64(item.Price),
+			Rid:         item.RID,
+			Name:        item.Name,
+			Sale:        int64(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  int64(item.TotalPrice),
+			NmId:        int64(item.NmID),
+			Brand:       item.Brand,
+			Status:      int64(item.Status),
+		})
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// orderAvro кодирует заказ в бинарный Avro по схеме testAvroSchema
+func orderAvro(t *testing.T, order *domain.Order) []byte {
+	t.Helper()
+	schema, err := avro.ParseFiles(testAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := avroAPI.Marshal(schema, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func orderJSON(t *testing.T, order *domain.Order) []byte {
+	t.Helper()
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// headerMessage сообщение со значением value и заголовками headers
+// (ключ, значение по очереди)
+func headerMessage(value []byte, headers ...string) *sarama.ConsumerMessage {
+	message := &sarama.ConsumerMessage{Topic: testTopic, Value: value}
+	for i := 0; i < len(headers); i += 2 {
+		message.Headers = append(message.Headers, &sarama.RecordHeader{
+			Key:   []byte(headers[i]),
+			Value: []byte(headers[i+1]),
+		})
+	}
+	return message
+}
+
+func newTestRegistry(t *testing.T, avroSchema string) *DecoderRegistry {
+	t.Helper()
+	r, err := NewDecoderRegistry(&config.KafkaConfig{AvroSchemaFile: avroSchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestDecoderRegistryFormats(t *testing.T) {
+	want := decoderTestOrder()
+
+	tests := []struct {
+		name    string
+		message *sarama.ConsumerMessage
+	}{
+		{
+			name:    "json without headers",
+			message: headerMessage(orderJSON(t, want)),
+		},
+		{
+			name:    "json with parameters",
+			message: headerMessage(orderJSON(t, want), "content-type", "application/json; charset=utf-8"),
+		},
+		{
+			name:    "protobuf",
+			message: headerMessage(orderProto(t, want), "content-type", "application/x-protobuf", "schema-version", "1"),
+		},
+		{
+			name:    "protobuf alias",
+			message: headerMessage(orderProto(t, want), "content-type", "application/protobuf"),
+		},
+		{
+			name:    "avro",
+			message: headerMessage(orderAvro(t, want), "content-type", "application/avro", "schema-version", "v1"),
+		},
+		{
+			name:    "avro alias",
+			message: headerMessage(orderAvro(t, want), "content-type", "avro/binary"),
+		},
+		{
+			name:    "header names are case-insensitive",
+			message: headerMessage(orderProto(t, want), "Content-Type", "application/x-protobuf", "Schema-Version", " 1 "),
+		},
+	}
+
+	r := newTestRegistry(t, testAvroSchema)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Decode(tt.message)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !got.DateCreated.Equal(want.DateCreated) {
+				t.Errorf("date_created = %v, want %v", got.DateCreated, want.DateCreated)
+			}
+			got.DateCreated = want.DateCreated
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("decoded order differs:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecoderRegistryRejects(t *testing.T) {
+	order := decoderTestOrder()
+
+	tests := []struct {
+		name    string
+		message *sarama.ConsumerMessage
+		wantErr string
+	}{
+		{
+			name:    "unknown content type",
+			message: headerMessage(orderJSON(t, order), "content-type", "text/plain"),
+			wantErr: "unsupported content type",
+		},
+		{
+			name:    "avro without schema file",
+			message: headerMessage(orderAvro(t, order), "content-type", "application/avro"),
+			wantErr: "unsupported content type",
+		},
+		{
+			name:    "malformed content type",
+			message: headerMessage(orderJSON(t, order), "content-type", "application/json; =broken"),
+			wantErr: "invalid content-type header",
+		},
+		{
+			name:    "future schema version",
+			message: headerMessage(orderJSON(t, order), "schema-version", "2"),
+			wantErr: "unsupported schema version",
+		},
+		{
+			name:    "zero schema version",
+			message: headerMessage(orderJSON(t, order), "schema-version", "0"),
+			wantErr: "unsupported schema version",
+		},
+		{
+			name:    "non-numeric schema version",
+			message: headerMessage(orderJSON(t, order), "schema-version", "latest"),
+			wantErr: "unsupported schema version",
+		},
+		{
+			name:    "broken json",
+			message: headerMessage([]byte(`{"order_uid":`)),
+			wantErr: "failed to decode application/json message",
+		},
+		{
+			name:    "json sent as protobuf",
+			message: headerMessage(orderJSON(t, order), "content-type", "application/x-protobuf"),
+			wantErr: "failed to decode application/x-protobuf message",
+		},
+	}
+
+	r := newTestRegistry(t, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := r.Decode(tt.message)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Decode error = %v, want %q", err, tt.wantErr)
+			}
+			if !isPermanent(err) {
+				t.Errorf("Decode error %v is not permanent", err)
+			}
+		})
+	}
+}
+
+// TestConsumeUndecodableToDeadLetter проверяет, что сообщения, которые
+// нельзя декодировать, сразу уходят в dead-letter топик, а их offset'ы
+// фиксируются
+func TestConsumeUndecodableToDeadLetter(t *testing.T) {
+	valid := decoderTestOrder()
+	log := []*sarama.ConsumerMessage{
+		headerMessage(orderJSON(t, valid), "schema-version", "2"),
+		headerMessage(orderJSON(t, valid), "content-type", "text/plain"),
+		headerMessage(orderJSON(t, valid)),
+	}
+	for i, message := range log {
+		message.Offset = int64(i)
+	}
+
+	handler := &savingHandler{}
+	c := newTestConsumer(t, AtLeastOnce, 1, handler)
+	producer := mocks.NewSyncProducer(t, nil)
+	c.dlq = &DeadLetterQueue{producer: producer, topic: "orders-dlq"}
+
+	var reasons []string
+	for range 2 {
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			for _, h := range msg.Headers {
+				if string(h.Key) == HeaderDLQReason {
+					reasons = append(reasons, string(h.Value))
+				}
+			}
+			return nil
+		})
+	}
+
+	session := consumeSession(t, c, context.Background(), map[int32]int64{}, log)
+	if err := producer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := session.offset(0); got != 3 {
+		t.Errorf("marked offset = %d, want 3", got)
+	}
+	if got := handler.savedOrders(); !slices.Equal(got, []string{valid.OrderUID}) {
+		t.Errorf("saved orders = %v, want only the valid one", got)
+	}
+	if len(reasons) != 2 ||
+		!strings.Contains(reasons[0], "unsupported schema version") ||
+		!strings.Contains(reasons[1], "unsupported content type") {
+		t.Errorf("dead-letter reasons = %q", reasons)
+	}
+}
@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+)
+
+// DeliveryGuarantee определяет, когда консьюмер фиксирует offset сообщения
+type DeliveryGuarantee string
+
+const (
+	// AtLeastOnce помечает offset только после успешной обработки сообщения
+	// или его передачи в dead-letter топик; помеченные offset'ы фиксируются
+	// раз в KafkaConfig.CommitInterval. После падения между записью в БД
+	// и фиксацией сообщения придут повторно; повтор безопасен благодаря
+	// идемпотентному сохранению заказов.
+	AtLeastOnce DeliveryGuarantee = "at-least-once"
+	// AtMostOnce фиксирует offset до обработки: после падения сообщение
+	// не придёт повторно, даже если не было сохранено.
+	AtMostOnce DeliveryGuarantee = "at-most-once"
+)
+
+// ParseDeliveryGuarantee разбирает режим доставки из конфигурации
+func ParseDeliveryGuarantee(s string) (DeliveryGuarantee, error) {
+	switch g := DeliveryGuarantee(s); g {
+	case AtLeastOnce, AtMostOnce:
+		return g, nil
+	default:
+		return "", fmt.Errorf("unknown delivery guarantee %q: expected %s or %s", s, AtLeastOnce, AtMostOnce)
+	}
+}
+
+// markMessage помечает сообщение обработанным. Помеченные offset'ы Sarama
+// фиксирует раз в KafkaConfig.CommitInterval и при завершении сессии, поэтому
+// обработка не ждёт ответа брокера на каждое сообщение.
+func markMessage(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) {
+	session.MarkMessage(message, "")
+}
+
+// commitMessage помечает сообщение обработанным и синхронно фиксирует offset.
+// Нужен режиму at-most-once: offset должен попасть в Kafka до начала обработки.
+func commitMessage(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) {
+	session.MarkMessage(message, "")
+	session.Commit()
+}
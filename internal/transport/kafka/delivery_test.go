@@ -0,0 +1,301 @@
+package kafka
+
+import (
+	"Order-tracker-service/config"
+	"Order-tracker-service/internal/domain"
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+const testTopic = "orders"
+
+// fakeSession сессия группы консьюмеров. Помеченные offset'ы считаются
+// зафиксированными: следующая сессия начинает с них.
+type fakeSession struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	marked  map[int32]int64
+	commits int
+}
+
+func newFakeSession(ctx context.Context, committed map[int32]int64) *fakeSession {
+	return &fakeSession{ctx: ctx, marked: committed}
+}
+
+func (s *fakeSession) Claims() map[string][]int32 { return nil }
+func (s *fakeSession) MemberID() string           { return "test-member" }
+func (s *fakeSession) GenerationID() int32        { return 1 }
+func (s *fakeSession) Context() context.Context   { return s.ctx }
+
+func (s *fakeSession) MarkOffset(_ string, partition int32, offset int64, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if offset > s.marked[partition] {
+		s.marked[partition] = offset
+	}
+}
+
+func (s *fakeSession) ResetOffset(_ string, partition int32, offset int64, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked[partition] = offset
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+func (s *fakeSession) Commit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commits++
+}
+
+func (s *fakeSession) offset(partition int32) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.marked[partition]
+}
+
+// fakeClaim выдаёт сообщения партиции начиная с initial. Канал закрывается
+// сразу после них или, если задан until, при его закрытии, как у Sarama
+// при завершении сессии.
+type fakeClaim struct {
+	partition int32
+	initial   int64
+	hwm       int64
+	messages  chan *sarama.ConsumerMessage
+}
+
+func newFakeClaim(partition int32, initial int64, log []*sarama.ConsumerMessage, until <-chan struct{}) *fakeClaim {
+	messages := make(chan *sarama.ConsumerMessage, len(log))
+	for _, message := range log[initial:] {
+		messages <- message
+	}
+	if until == nil {
+		close(messages)
+	} else {
+		go func() {
+			<-until
+			close(messages)
+		}()
+	}
+	return &fakeClaim{partition: partition, initial: initial, hwm: int64(len(log)), messages: messages}
+}
+
+func (c *fakeClaim) Topic() string                            { return testTopic }
+func (c *fakeClaim) Partition() int32                         { return c.partition }
+func (c *fakeClaim) InitialOffset() int64                     { return c.initial }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return c.hwm }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// orderLog сообщения партиции с заказами uids; offset равен индексу
+func orderLog(t *testing.T, partition int32, uids ...string) []*sarama.ConsumerMessage {
+	t.Helper()
+	log := make([]*sarama.ConsumerMessage, len(uids))
+	for i, uid := range uids {
+		value, err := json.Marshal(&domain.Order{OrderUID: uid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		log[i] = &sarama.ConsumerMessage{
+			Topic:     testTopic,
+			Partition: partition,
+			Offset:    int64(i),
+			Key:       []byte(uid),
+			Value:     value,
+		}
+	}
+	return log
+}
+
+// savingHandler запоминает сохранённые заказы; afterSave вызывается после
+// каждой записи, до того как консьюмер подтвердит сообщение
+type savingHandler struct {
+	mu        sync.Mutex
+	saved     []string
+	afterSave func(order *domain.Order)
+}
+
+func (h *savingHandler) HandleOrder(_ context.Context, order *domain.Order) error {
+	h.mu.Lock()
+	h.saved = append(h.saved, order.OrderUID)
+	h.mu.Unlock()
+	if h.afterSave != nil {
+		h.afterSave(order)
+	}
+	return nil
+}
+
+func (h *savingHandler) savedOrders() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.saved)
+}
+
+func newTestConsumer(t *testing.T, guarantee DeliveryGuarantee, workers int, handler MessageHandler) *Consumer {
+	t.Helper()
+	cfg := &config.KafkaConfig{
+		Topic:        testTopic,
+		GroupID:      "test-group",
+		BatchSize:    1,
+		Workers:      workers,
+		StallTimeout: time.Minute,
+	}
+	decoders, err := NewDecoderRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Consumer{
+		config:    cfg,
+		handler:   handler,
+		decoders:  decoders,
+		guarantee: guarantee,
+		stats:     newConsumerStats(),
+		pause:     newPauseGate(),
+	}
+}
+
+// consumeSession обрабатывает партицию в одной сессии, начиная с
+// зафиксированного offset'а, и возвращает сессию для проверки offset'ов
+func consumeSession(t *testing.T, c *Consumer, ctx context.Context, committed map[int32]int64, log []*sarama.ConsumerMessage) *fakeSession {
+	t.Helper()
+	session := newFakeSession(ctx, committed)
+	claim := newFakeClaim(0, committed[0], log, nil)
+
+	done := make(chan error, 1)
+	go func() { done <- c.ConsumeClaim(session, claim) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ConsumeClaim: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ConsumeClaim did not return")
+	}
+	return session
+}
+
+// TestConsumeClaimCrashAfterSave моделирует падение между записью заказа в БД
+// и фиксацией offset'а: сессия завершается сразу после сохранения второго заказа
+func TestConsumeClaimCrashAfterSave(t *testing.T) {
+	tests := []struct {
+		name       string
+		guarantee  DeliveryGuarantee
+		workers    int
+		wantOffset int64    // offset, с которого начнётся следующая сессия
+		wantSaved  []string // записи в БД за обе сессии
+		wantCommit bool     // был ли синхронный коммит
+	}{
+		{
+			name:       "at-least-once redelivers unconfirmed message",
+			guarantee:  AtLeastOnce,
+			workers:    1,
+			wantOffset: 1,
+			wantSaved:  []string{"order-0", "order-1", "order-1"},
+		},
+		{
+			name:       "at-least-once parallel redelivers unconfirmed message",
+			guarantee:  AtLeastOnce,
+			workers:    4,
+			wantOffset: 1,
+			wantSaved:  []string{"order-0", "order-1", "order-1"},
+		},
+		{
+			name:       "at-most-once does not redeliver",
+			guarantee:  AtMostOnce,
+			workers:    1,
+			wantOffset: 2,
+			wantSaved:  []string{"order-0", "order-1"},
+			wantCommit: true,
+		},
+		{
+			name:       "at-most-once parallel does not redeliver",
+			guarantee:  AtMostOnce,
+			workers:    4,
+			wantOffset: 2,
+			wantSaved:  []string{"order-0", "order-1"},
+			wantCommit: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := orderLog(t, 0, "order-0", "order-1")
+			handler := &savingHandler{}
+			c := newTestConsumer(t, tt.guarantee, tt.workers, handler)
+			committed := map[int32]int64{}
+
+			// Первая сессия: второй заказ сохранён, но сессия обрывается
+			// до его подтверждения. Первый заказ к этому моменту подтверждён.
+			ctx, crash := context.WithCancel(context.Background())
+			var first *fakeSession
+			handler.afterSave = func(order *domain.Order) {
+				if order.OrderUID != "order-1" {
+					return
+				}
+				// В параллельном режиме первый заказ обрабатывает другой
+				// воркер: дожидаемся его сохранения и подтверждения
+				firstDone := func() bool {
+					saved := slices.Contains(handler.savedOrders(), "order-0")
+					return saved && (tt.guarantee == AtMostOnce || first.offset(0) >= 1)
+				}
+				for deadline := time.Now().Add(5 * time.Second); !firstDone() && time.Now().Before(deadline); {
+					time.Sleep(time.Millisecond)
+				}
+				crash()
+			}
+			first = newFakeSession(ctx, committed)
+			claim := newFakeClaim(0, 0, log, ctx.Done())
+			if err := c.ConsumeClaim(first, claim); err != nil {
+				t.Fatalf("ConsumeClaim: %v", err)
+			}
+
+			if got := first.offset(0); got != tt.wantOffset {
+				t.Errorf("offset after crash = %d, want %d", got, tt.wantOffset)
+			}
+			if tt.wantCommit != (first.commits > 0) {
+				t.Errorf("synchronous commits = %d, want any: %v", first.commits, tt.wantCommit)
+			}
+
+			// Вторая сессия начинает с зафиксированного offset'а
+			handler.afterSave = nil
+			consumeSession(t, c, context.Background(), first.marked, log)
+
+			// Заказы с разными ключами воркеры сохраняют в любом порядке
+			got := handler.savedOrders()
+			slices.Sort(got)
+			if !slices.Equal(got, tt.wantSaved) {
+				t.Errorf("saved orders = %v, want %v", got, tt.wantSaved)
+			}
+		})
+	}
+}
+
+// TestConsumeClaimMarksProcessed проверяет, что в режиме at-least-once
+// обработанные сообщения только помечаются, без синхронного коммита на каждое
+func TestConsumeClaimMarksProcessed(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		log := orderLog(t, 0, "order-0", "order-1", "order-2")
+		handler := &savingHandler{}
+		c := newTestConsumer(t, AtLeastOnce, workers, handler)
+
+		session := consumeSession(t, c, context.Background(), map[int32]int64{}, log)
+
+		if got := session.offset(0); got != 3 {
+			t.Errorf("workers=%d: marked offset = %d, want 3", workers, got)
+		}
+		if session.commits != 0 {
+			t.Errorf("workers=%d: synchronous commits = %d, want 0", workers, session.commits)
+		}
+		if got := len(handler.savedOrders()); got != 3 {
+			t.Errorf("workers=%d: saved %d orders, want 3", workers, got)
+		}
+	}
+}
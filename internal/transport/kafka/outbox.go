@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"Order-tracker-service/config"
+	"Order-tracker-service/internal/repository"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Заголовки событий, публикуемых из outbox
+const (
+	HeaderEventType = "event-type"
+	HeaderEventID   = "event-id" // id строки outbox, по нему получатели отбрасывают повторы
+)
+
+// OutboxRelay публикует события из outbox в Kafka в порядке их записи.
+// Публикация at-least-once: если отметка о публикации не сохранилась,
+// событие будет отправлено повторно с тем же event-id. Порядок событий
+// одного заказа совпадает с порядком их сохранения.
+type OutboxRelay struct {
+	store    repository.OutboxRepository
+	producer *Producer
+	config   *config.OutboxConfig
+	retry    retryPolicy
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewOutboxRelay создает воркер публикации событий
+func NewOutboxRelay(cfg *config.OutboxConfig, store repository.OutboxRepository, producer *Producer) (*OutboxRelay, error) {
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("outbox topic is required")
+	}
+	if cfg.BatchSize <= 0 {
+		return nil, fmt.Errorf("outbox batch size must be positive, got %d", cfg.BatchSize)
+	}
+	if cfg.PruneInterval <= 0 {
+		return nil, fmt.Errorf("outbox prune interval must be positive, got %v", cfg.PruneInterval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &OutboxRelay{
+		store:    store,
+		producer: producer,
+		config:   cfg,
+		retry: retryPolicy{
+			baseDelay: cfg.RetryBackoff,
+			maxDelay:  cfg.RetryMaxBackoff,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Start запускает публикацию и очистку опубликованных событий
+func (r *OutboxRelay) Start() {
+	r.wg.Add(2)
+	go func() {
+		defer r.wg.Done()
+		r.relay()
+	}()
+	go func() {
+		defer r.wg.Done()
+		r.prune()
+	}()
+
+	log.Printf("Outbox relay started, publishing to topic: %s", r.config.Topic)
+}
+
+// Stop останавливает воркер, дождавшись окончания текущего прохода
+func (r *OutboxRelay) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	log.Println("Outbox relay stopped")
+}
+
+// relay публикует события, пока не остановлен. Пока в outbox есть события,
+// проходы идут подряд; после ошибки следующий проход откладывается
+// с экспоненциальной задержкой.
+func (r *OutboxRelay) relay() {
+	failures := 0
+	for {
+		published, err := r.store.PublishPending(r.config.BatchSize, r.publish)
+
+		delay := r.config.PollInterval
+		switch {
+		case err != nil:
+			failures++
+			delay = r.retry.backoff(failures)
+			log.Printf("Error publishing outbox events (published %d, retry in %v): %v", published, delay, err)
+		case published == r.config.BatchSize:
+			failures = 0
+			delay = 0
+		default:
+			failures = 0
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// publish отправляет одно событие
+func (r *OutboxRelay) publish(event *repository.OutboxEvent) error {
+	return r.producer.SendMessage(r.config.Topic, &Message{
+		Key:   event.OrderUID,
+		Value: event.Payload,
+		Headers: map[string]string{
+			HeaderContentType:   ContentTypeJSON,
+			HeaderSchemaVersion: strconv.Itoa(CurrentSchemaVersion),
+			HeaderEventType:     event.EventType,
+			HeaderEventID:       strconv.FormatInt(event.ID, 10),
+		},
+	})
+}
+
+// prune периодически удаляет опубликованные события старше Retention
+func (r *OutboxRelay) prune() {
+	ticker := time.NewTicker(r.config.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := r.store.PrunePublished(time.Now().Add(-r.config.Retention))
+			if err != nil {
+				log.Printf("Error pruning outbox: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("Pruned %d published outbox event(s)", deleted)
+			}
+		}
+	}
+}
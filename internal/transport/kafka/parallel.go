@@ -0,0 +1,202 @@
+package kafka
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// workerQueueSize сколько сообщений может ждать в очереди одного воркера
+const workerQueueSize = 16
+
+// inflight отслеживает сообщения партиции, выданные воркерам, в порядке
+// получения. Offset можно фиксировать только до последнего сообщения
+// непрерывного завершённого префикса: иначе при падении потеряются
+// сообщения, которые ещё обрабатываются.
+type inflight struct {
+	order    *list.List              // сообщения в порядке получения
+	byOffset map[int64]*list.Element // элементы order по offset
+}
+
+type inflightEntry struct {
+	message *sarama.ConsumerMessage
+	done    bool
+}
+
+func newInflight() *inflight {
+	return &inflight{
+		order:    list.New(),
+		byOffset: make(map[int64]*list.Element),
+	}
+}
+
+// add регистрирует выданное воркеру сообщение
+func (f *inflight) add(message *sarama.ConsumerMessage) {
+	f.byOffset[message.Offset] = f.order.PushBack(&inflightEntry{message: message})
+}
+
+// complete отмечает сообщение обработанным и возвращает последнее сообщение
+// непрерывного завершённого префикса или nil, если префикс не сдвинулся
+func (f *inflight) complete(message *sarama.ConsumerMessage) *sarama.ConsumerMessage {
+	if el, ok := f.byOffset[message.Offset]; ok {
+		el.Value.(*inflightEntry).done = true
+	}
+
+	var last *sarama.ConsumerMessage
+	for front := f.order.Front(); front != nil; front = f.order.Front() {
+		entry := front.Value.(*inflightEntry)
+		if !entry.done {
+			break
+		}
+		last = entry.message
+		f.order.Remove(front)
+		delete(f.byOffset, entry.message.Offset)
+	}
+	return last
+}
+
+// workerFor выбирает воркера по ключу сообщения, чтобы сообщения одного
+// заказа (producer использует order_uid как ключ) обрабатывались по порядку
+func workerFor(message *sarama.ConsumerMessage, workers int) int {
+	h := fnv.New32a()
+	h.Write(message.Key)
+	return int(h.Sum32() % uint32(workers))
+}
+
+// workerPool воркеры одной партиции. Результаты приходят в done: его читает
+// цикл выдачи сообщений, а после остановки — stop.
+type workerPool struct {
+	queues []chan *sarama.ConsumerMessage
+	done   chan *sarama.ConsumerMessage
+	wg     sync.WaitGroup
+}
+
+// newWorkerPool запускает workers воркеров с очередями на queueSize
+// сообщений. handle возвращает false, если сообщение не обработано и его
+// offset нельзя фиксировать.
+func newWorkerPool(workers, queueSize int, handle func(message *sarama.ConsumerMessage) bool) *workerPool {
+	p := &workerPool{
+		queues: make([]chan *sarama.ConsumerMessage, workers),
+		done:   make(chan *sarama.ConsumerMessage, workers*(queueSize+1)),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan *sarama.ConsumerMessage, queueSize)
+		p.wg.Add(1)
+		go func(queue <-chan *sarama.ConsumerMessage) {
+			defer p.wg.Done()
+			for message := range queue {
+				if handle(message) {
+					p.done <- message
+				}
+			}
+		}(p.queues[i])
+	}
+	return p
+}
+
+// queue возвращает очередь воркера, обрабатывающего сообщение
+func (p *workerPool) queue(message *sarama.ConsumerMessage) chan<- *sarama.ConsumerMessage {
+	return p.queues[workerFor(message, len(p.queues))]
+}
+
+// stop закрывает очереди и передаёт в complete результаты, пока воркеры
+// завершаются. Буфер done может быть заполнен: пока результаты ждали в нём,
+// цикл выдачи продолжал пополнять очереди, поэтому без чтения done воркер
+// заблокировался бы на отправке, и stop никогда не вернулся бы.
+func (p *workerPool) stop(complete func(message *sarama.ConsumerMessage)) {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	go func() {
+		p.wg.Wait()
+		close(p.done)
+	}()
+	for message := range p.done {
+		complete(message)
+	}
+}
+
+// consumeParallel обрабатывает сообщения партиции несколькими воркерами.
+// Сообщения с одинаковым ключом попадают к одному воркеру и обрабатываются
+// в порядке получения; offset продвигается только до наибольшего
+// непрерывно обработанного сообщения.
+func (c *Consumer) consumeParallel(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx := session.Context()
+
+	// После завершения сессии оставшиеся сообщения не обрабатываем:
+	// их получит следующий владелец партиции
+	pool := newWorkerPool(c.config.Workers, workerQueueSize, func(message *sarama.ConsumerMessage) bool {
+		return ctx.Err() == nil && c.handleMessage(ctx, message)
+	})
+	done := pool.done
+
+	pending := newInflight()
+	// Сообщение отпускается после пометки offset'а: пауза подтверждается
+	// только когда offset'ы сообщений в работе помечены. Статистика тоже
+	// сдвигается только по непрерывному префиксу, иначе отставание было бы
+	// меньше фиксируемого.
+	complete := func(message *sarama.ConsumerMessage) {
+		last := pending.complete(message)
+		if last != nil && ctx.Err() == nil {
+			c.stats.advance(last)
+			if c.guarantee == AtLeastOnce {
+				markMessage(session, last)
+			}
+		}
+		c.pause.release(message.Partition)
+	}
+	for {
+		select {
+		case message := <-claim.Messages():
+			if message == nil {
+				pool.stop(complete)
+				return nil
+			}
+			c.stats.received(claim)
+
+			// Пока партиция на паузе, продолжаем принимать результаты воркеров,
+			// чтобы сообщения в работе завершились и их offset'ы зафиксировались
+			for {
+				acquired, changed := c.pause.tryAcquire(message.Partition)
+				if acquired {
+					break
+				}
+				select {
+				case <-changed:
+				case finished := <-done:
+					complete(finished)
+				case <-ctx.Done():
+					pool.stop(complete)
+					return nil
+				}
+			}
+
+			if c.guarantee == AtMostOnce {
+				commitMessage(session, message)
+			}
+			pending.add(message)
+
+			queue := pool.queue(message)
+			for sent := false; !sent; {
+				select {
+				case queue <- message:
+					sent = true
+				case finished := <-done:
+					complete(finished)
+				case <-ctx.Done():
+					pool.stop(complete)
+					return nil
+				}
+			}
+
+		case finished := <-done:
+			complete(finished)
+
+		case <-ctx.Done():
+			pool.stop(complete)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package kafka
+
+import (
+	"Order-tracker-service/internal/domain"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+func TestInflightComplete(t *testing.T) {
+	// none — префикс не сдвинулся, complete вернул nil
+	const none = -1
+
+	tests := []struct {
+		name     string
+		added    int     // выдано сообщений с offset'ами 0..added-1
+		complete []int64 // порядок завершения
+		want     []int64 // последний offset префикса после каждого завершения
+	}{
+		{
+			name:     "in order",
+			added:    3,
+			complete: []int64{0, 1, 2},
+			want:     []int64{0, 1, 2},
+		},
+		{
+			name:     "reverse order",
+			added:    3,
+			complete: []int64{2, 1, 0},
+			want:     []int64{none, none, 2},
+		},
+		{
+			name:     "gap filled later",
+			added:    5,
+			complete: []int64{0, 2, 3, 1, 4},
+			want:     []int64{0, none, none, 3, 4},
+		},
+		{
+			name:     "head completes last",
+			added:    4,
+			complete: []int64{1, 3, 2, 0},
+			want:     []int64{none, none, none, 3},
+		},
+		{
+			name:     "duplicate completion",
+			added:    2,
+			complete: []int64{1, 1, 0},
+			want:     []int64{none, none, 1},
+		},
+		{
+			name:     "unknown offset",
+			added:    2,
+			complete: []int64{7, 0},
+			want:     []int64{none, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newInflight()
+			messages := make(map[int64]*sarama.ConsumerMessage)
+			for offset := range int64(tt.added) {
+				messages[offset] = &sarama.ConsumerMessage{Offset: offset}
+				f.add(messages[offset])
+			}
+
+			for i, offset := range tt.complete {
+				message, ok := messages[offset]
+				if !ok {
+					message = &sarama.ConsumerMessage{Offset: offset}
+				}
+
+				got := int64(none)
+				if last := f.complete(message); last != nil {
+					got = last.Offset
+				}
+				if got != tt.want[i] {
+					t.Errorf("complete(%d) = %d, want %d", offset, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInflightCompleteReleasesEntries(t *testing.T) {
+	f := newInflight()
+	for offset := range int64(100) {
+		f.add(&sarama.ConsumerMessage{Offset: offset})
+	}
+	for offset := int64(99); offset >= 0; offset-- {
+		f.complete(&sarama.ConsumerMessage{Offset: offset})
+	}
+
+	if f.order.Len() != 0 || len(f.byOffset) != 0 {
+		t.Errorf("after completing all messages: %d in order, %d by offset, want 0", f.order.Len(), len(f.byOffset))
+	}
+}
+
+func TestWorkerFor(t *testing.T) {
+	const workers = 4
+
+	used := make(map[int]bool)
+	for i := range 100 {
+		key := []byte(fmt.Sprintf("order-%d", i))
+		worker := workerFor(&sarama.ConsumerMessage{Key: key}, workers)
+		if worker < 0 || worker >= workers {
+			t.Fatalf("workerFor(%s) = %d, want 0..%d", key, worker, workers-1)
+		}
+		if again := workerFor(&sarama.ConsumerMessage{Key: key}, workers); again != worker {
+			t.Fatalf("workerFor(%s) is not stable: %d then %d", key, worker, again)
+		}
+		used[worker] = true
+	}
+
+	if len(used) != workers {
+		t.Errorf("100 keys used %d of %d workers", len(used), workers)
+	}
+}
+
+// TestConsumeParallelKeyOrder проверяет, что сообщения одного заказа
+// обрабатываются в порядке получения, а offset доходит до последнего
+func TestConsumeParallelKeyOrder(t *testing.T) {
+	const orders, updates = 8, 5
+
+	var uids []string
+	for update := range updates {
+		for order := range orders {
+			uids = append(uids, fmt.Sprintf("order-%d/%d", order, update))
+		}
+	}
+	log := orderLog(t, 0, uids...)
+	// Ключ — заказ без номера изменения
+	for _, message := range log {
+		message.Key = message.Key[:len("order-0")]
+	}
+
+	handler := &savingHandler{}
+	c := newTestConsumer(t, AtLeastOnce, 4, handler)
+	session := consumeSession(t, c, context.Background(), map[int32]int64{}, log)
+
+	if got, want := session.offset(0), int64(len(log)); got != want {
+		t.Errorf("marked offset = %d, want %d", got, want)
+	}
+
+	next := make(map[string]int)
+	for _, uid := range handler.savedOrders() {
+		var order, update int
+		fmt.Sscanf(uid, "order-%d/%d", &order, &update)
+		key := fmt.Sprint(order)
+		if update != next[key] {
+			t.Fatalf("order %d: update %d processed before update %d", order, update, next[key])
+		}
+		next[key]++
+	}
+}
+
+// TestWorkerPoolStopWithFullResults проверяет остановку после завершения
+// сессии, когда буфер результатов заполнен и воркер ждёт места в нём
+func TestWorkerPoolStopWithFullResults(t *testing.T) {
+	log := orderLog(t, 0, "order-0", "order-1", "order-2")
+
+	// Очередь на одно сообщение: буфер результатов вмещает два, воркер
+	// с третьим результатом блокируется, а цикл выдачи уже не читает done
+	handled := make(chan struct{}, len(log))
+	pool := newWorkerPool(1, 1, func(*sarama.ConsumerMessage) bool {
+		handled <- struct{}{}
+		return true
+	})
+	for _, message := range log {
+		pool.queue(message) <- message
+	}
+	for range log {
+		<-handled
+	}
+
+	var completed []int64
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		pool.stop(func(message *sarama.ConsumerMessage) {
+			completed = append(completed, message.Offset)
+		})
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop did not return with a full result buffer")
+	}
+	if want := []int64{0, 1, 2}; !slices.Equal(completed, want) {
+		t.Errorf("completed offsets = %v, want %v", completed, want)
+	}
+}
+
+// blockingHandler держит обработку заказов, пока не закрыт release
+type blockingHandler struct {
+	savingHandler
+	started chan string
+	release chan struct{}
+}
+
+func (h *blockingHandler) HandleOrder(ctx context.Context, order *domain.Order) error {
+	h.started <- order.OrderUID
+	select {
+	case <-h.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return h.savingHandler.HandleOrder(ctx, order)
+}
+
+// TestConsumeParallelPause проверяет, что пауза дожидается сообщений в работе
+// и фиксирует их offset'ы, а новые сообщения не выдаются воркерам до
+// возобновления
+func TestConsumeParallelPause(t *testing.T) {
+	log := orderLog(t, 0, "order-0", "order-1", "order-2", "order-3")
+	handler := &blockingHandler{
+		started: make(chan string, len(log)),
+		release: make(chan struct{}),
+	}
+	c := newTestConsumer(t, AtLeastOnce, 4, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := newFakeSession(ctx, map[int32]int64{})
+	// Первые два сообщения уже в работе, остальные придут после паузы
+	claim := newFakeClaim(0, 0, log[:2], ctx.Done())
+	claim.hwm = int64(len(log))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.ConsumeClaim(session, claim)
+	}()
+
+	for range 2 {
+		<-handler.started
+	}
+
+	// Пауза подтверждается только после завершения сообщений в работе
+	paused := make(chan error, 1)
+	go func() { paused <- pauseAndDrain(c.pause, []int32{0}) }()
+	select {
+	case err := <-paused:
+		t.Fatalf("pause returned with messages in flight: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(handler.release)
+	if err := <-paused; err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if got := session.offset(0); got != 2 {
+		t.Errorf("marked offset after pause = %d, want 2", got)
+	}
+
+	// Сообщения, полученные во время паузы, воркерам не выдаются
+	claimMore(claim, log[2:])
+	select {
+	case uid := <-handler.started:
+		t.Fatalf("order %s dispatched while paused", uid)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := c.pause.resume([]int32{0}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		<-handler.started
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for session.offset(0) < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := session.offset(0); got != 4 {
+		t.Errorf("marked offset after resume = %d, want 4", got)
+	}
+
+	cancel()
+	wg.Wait()
+
+	got := handler.savedOrders()
+	slices.Sort(got)
+	if want := []string{"order-0", "order-1", "order-2", "order-3"}; !slices.Equal(got, want) {
+		t.Errorf("saved orders = %v, want %v", got, want)
+	}
+}
+
+// pauseAndDrain приостанавливает партиции в gate и ждёт сообщений в
+// работе, как Consumer.Pause, но без обращения к группе Sarama
+func pauseAndDrain(g *pauseGate, partitions []int32) error {
+	g.pause(partitions)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return g.drain(ctx, partitions)
+}
+
+// claimMore досылает сообщения в открытый канал claim
+func claimMore(claim *fakeClaim, messages []*sarama.ConsumerMessage) {
+	for _, message := range messages {
+		claim.messages <- message
+	}
+}
@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// Партиционеры producer'а
+const (
+	PartitionerHash    = "hash"    // FNV-1a, партиционер Sarama по умолчанию
+	PartitionerMurmur2 = "murmur2" // как DefaultPartitioner Java клиента Kafka
+	PartitionerManual  = "manual"  // партиция задаётся в Message.Partition
+)
+
+// newPartitioner возвращает конструктор партиционера по названию
+func newPartitioner(name string) (sarama.PartitionerConstructor, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", PartitionerHash:
+		return sarama.NewHashPartitioner, nil
+	case PartitionerMurmur2:
+		// Java клиент берёт хэш по модулю 0x7fffffff, что соответствует WithAbsFirst
+		return sarama.NewCustomPartitioner(
+			sarama.WithAbsFirst(),
+			sarama.WithCustomHashFunction(newMurmur2),
+		), nil
+	case PartitionerManual:
+		return sarama.NewManualPartitioner, nil
+	default:
+		return nil, fmt.Errorf("unknown partitioner %q, expected %s, %s or %s",
+			name, PartitionerHash, PartitionerMurmur2, PartitionerManual)
+	}
+}
+
+// murmur2 реализация MurmurHash2 из Java клиента Kafka (Utils.murmur2).
+// Хэш считается по всему ключу целиком, поэтому данные накапливаются до Sum32.
+type murmur2 struct {
+	data []byte
+}
+
+func newMurmur2() hash.Hash32 {
+	return &murmur2{}
+}
+
+func (h *murmur2) Write(p []byte) (int, error) {
+	h.data = append(h.data, p...)
+	return len(p), nil
+}
+
+func (h *murmur2) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint32(b, h.Sum32())
+}
+
+func (h *murmur2) Reset() { h.data = h.data[:0] }
+
+func (h *murmur2) Size() int { return 4 }
+
+func (h *murmur2) BlockSize() int { return 4 }
+
+func (h *murmur2) Sum32() uint32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	data := h.data
+	length := len(data)
+	hash := seed ^ uint32(length)
+
+	for ; len(data) >= 4; data = data[4:] {
+		k := binary.LittleEndian.Uint32(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		hash *= m
+		hash ^= k
+	}
+
+	switch len(data) {
+	case 3:
+		hash ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		hash ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		hash ^= uint32(data[0])
+		hash *= m
+	}
+
+	hash ^= hash >> 13
+	hash *= m
+	hash ^= hash >> 15
+	return hash
+}
@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// pauseGate хранит, какие партиции приостановлены, и считает сообщения,
+// которые сейчас обрабатываются. Пауза Sarama лишь прекращает выборку новых
+// сообщений, а уже полученные продолжают поступать из буфера claim.Messages(),
+// поэтому обработчики партиций перед каждым сообщением проходят через gate.
+type pauseGate struct {
+	mu         sync.Mutex
+	all        bool           // приостановлены все партиции, включая назначенные позже
+	partitions map[int32]bool // приостановленные партиции, если all == false
+	busy       map[int32]int  // сообщений в обработке по партициям
+	changed    chan struct{}  // закрывается и пересоздаётся при каждом изменении состояния
+}
+
+func newPauseGate() *pauseGate {
+	return &pauseGate{
+		partitions: make(map[int32]bool),
+		busy:       make(map[int32]int),
+		changed:    make(chan struct{}),
+	}
+}
+
+// notify будит всех ожидающих изменения состояния. Вызывается под g.mu.
+func (g *pauseGate) notify() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// isPaused сообщает, приостановлена ли партиция. Вызывается под g.mu.
+func (g *pauseGate) isPaused(partition int32) bool {
+	return g.all || g.partitions[partition]
+}
+
+// paused сообщает, приостановлена ли партиция
+func (g *pauseGate) paused(partition int32) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.isPaused(partition)
+}
+
+// tryAcquire регистрирует сообщение партиции в обработке, если партиция не
+// приостановлена. Иначе возвращает канал, который закроется при изменении
+// состояния, чтобы вызывающий мог ждать его вместе с другими событиями.
+func (g *pauseGate) tryAcquire(partition int32) (bool, <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.isPaused(partition) {
+		return false, g.changed
+	}
+	g.busy[partition]++
+	return true, nil
+}
+
+// acquire ждёт, пока партиция не будет возобновлена, и регистрирует
+// сообщение в обработке. Возвращает false, если завершилась сессия.
+func (g *pauseGate) acquire(ctx context.Context, partition int32) bool {
+	for {
+		ok, changed := g.tryAcquire(partition)
+		if ok {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// release отмечает, что обработка сообщения партиции завершена
+func (g *pauseGate) release(partition int32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.busy[partition] > 0 {
+		g.busy[partition]--
+		if g.busy[partition] == 0 {
+			delete(g.busy, partition)
+		}
+		g.notify()
+	}
+}
+
+// reset обнуляет счётчики обработки. Вызывается в конце сессии, когда все
+// обработчики партиций уже завершились и сообщения, которые они не успели
+// обработать, никогда не будут отпущены.
+func (g *pauseGate) reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	clear(g.busy)
+	g.notify()
+}
+
+// pause приостанавливает указанные партиции, все при пустом списке
+func (g *pauseGate) pause(partitions []int32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(partitions) == 0 {
+		g.all = true
+		clear(g.partitions)
+	}
+	for _, partition := range partitions {
+		if !g.all {
+			g.partitions[partition] = true
+		}
+	}
+	g.notify()
+}
+
+// resume возобновляет указанные партиции, все при пустом списке.
+// Выборочно возобновить партиции после паузы всего консьюмера нельзя:
+// неизвестно, какие партиции будут назначены после ребалансировки.
+func (g *pauseGate) resume(partitions []int32) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(partitions) == 0 {
+		g.all = false
+		clear(g.partitions)
+	} else {
+		if g.all {
+			return fmt.Errorf("all partitions are paused, resume all of them instead")
+		}
+		for _, partition := range partitions {
+			delete(g.partitions, partition)
+		}
+	}
+	g.notify()
+	return nil
+}
+
+// drain ждёт завершения обработки сообщений указанных партиций, всех при
+// пустом списке. Возвращает ошибку, если ctx завершился раньше.
+func (g *pauseGate) drain(ctx context.Context, partitions []int32) error {
+	for {
+		g.mu.Lock()
+		pending := 0
+		if len(partitions) == 0 {
+			for _, n := range g.busy {
+				pending += n
+			}
+		}
+		for _, partition := range partitions {
+			pending += g.busy[partition]
+		}
+		changed := g.changed
+		g.mu.Unlock()
+
+		if pending == 0 {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("%d message(s) still in flight: %w", pending, ctx.Err())
+		}
+	}
+}
+
+// Pause приостанавливает потребление указанных партиций топика, всех при
+// пустом списке, и ждёт, пока обработаются сообщения, уже находящиеся в
+// работе. Пауза действует и после ребалансировки. Если ctx завершился до
+// окончания обработки, пауза остаётся в силе, а возвращается ошибка.
+func (c *Consumer) Pause(ctx context.Context, partitions []int32) error {
+	c.pause.pause(partitions)
+	if len(partitions) == 0 {
+		c.consumer.PauseAll()
+		log.Printf("Kafka consumer paused on all partitions")
+	} else {
+		c.consumer.Pause(map[string][]int32{c.config.Topic: partitions})
+		log.Printf("Kafka consumer paused on partitions %v", partitions)
+	}
+
+	if err := c.pause.drain(ctx, partitions); err != nil {
+		return fmt.Errorf("failed to drain in-flight messages: %w", err)
+	}
+	return nil
+}
+
+// Resume возобновляет потребление указанных партиций, всех при пустом списке
+func (c *Consumer) Resume(partitions []int32) error {
+	if err := c.pause.resume(partitions); err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		c.consumer.ResumeAll()
+		log.Printf("Kafka consumer resumed on all partitions")
+	} else {
+		c.consumer.Resume(map[string][]int32{c.config.Topic: partitions})
+		log.Printf("Kafka consumer resumed on partitions %v", partitions)
+	}
+	return nil
+}
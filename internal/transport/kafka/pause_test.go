@@ -0,0 +1,216 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPauseGatePauseResume(t *testing.T) {
+	tests := []struct {
+		name       string
+		pause      [][]int32 // вызовы pause; nil — все партиции
+		resume     [][]int32 // вызовы resume
+		wantErr    bool      // последний resume вернул ошибку
+		wantPaused []int32   // из партиций 0..3
+	}{
+		{
+			name:       "selected partitions",
+			pause:      [][]int32{{1, 2}},
+			wantPaused: []int32{1, 2},
+		},
+		{
+			name:       "all partitions",
+			pause:      [][]int32{nil},
+			wantPaused: []int32{0, 1, 2, 3},
+		},
+		{
+			name:       "resume one of paused",
+			pause:      [][]int32{{1, 2}},
+			resume:     [][]int32{{2}},
+			wantPaused: []int32{1},
+		},
+		{
+			name:       "resume all after selected",
+			pause:      [][]int32{{1}, {3}},
+			resume:     [][]int32{nil},
+			wantPaused: nil,
+		},
+		{
+			name:       "selected after all keeps all",
+			pause:      [][]int32{nil, {1}},
+			wantPaused: []int32{0, 1, 2, 3},
+		},
+		{
+			name:       "resume selected after all is rejected",
+			pause:      [][]int32{nil},
+			resume:     [][]int32{{1}},
+			wantErr:    true,
+			wantPaused: []int32{0, 1, 2, 3},
+		},
+		{
+			name:       "resume all after all",
+			pause:      [][]int32{nil},
+			resume:     [][]int32{nil},
+			wantPaused: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newPauseGate()
+			for _, partitions := range tt.pause {
+				g.pause(partitions)
+			}
+			var err error
+			for _, partitions := range tt.resume {
+				err = g.resume(partitions)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resume error = %v, want error: %v", err, tt.wantErr)
+			}
+
+			var paused []int32
+			for partition := range int32(4) {
+				if g.paused(partition) {
+					paused = append(paused, partition)
+				}
+			}
+			if !slices.Equal(paused, tt.wantPaused) {
+				t.Errorf("paused = %v, want %v", paused, tt.wantPaused)
+			}
+		})
+	}
+}
+
+func TestPauseGateTryAcquire(t *testing.T) {
+	g := newPauseGate()
+	g.pause([]int32{0})
+
+	ok, changed := g.tryAcquire(0)
+	if ok {
+		t.Fatal("tryAcquire succeeded on paused partition")
+	}
+	if ok, _ := g.tryAcquire(1); !ok {
+		t.Fatal("tryAcquire failed on running partition")
+	}
+
+	if err := g.resume([]int32{0}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("changed channel was not closed on resume")
+	}
+	if ok, _ := g.tryAcquire(0); !ok {
+		t.Fatal("tryAcquire failed after resume")
+	}
+}
+
+func TestPauseGateAcquireWaitsForResume(t *testing.T) {
+	g := newPauseGate()
+	g.pause(nil)
+
+	acquired := make(chan bool, 1)
+	go func() { acquired <- g.acquire(context.Background(), 0) }()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire returned while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := g.resume(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !<-acquired {
+		t.Fatal("acquire returned false after resume")
+	}
+}
+
+func TestPauseGateAcquireSessionEnd(t *testing.T) {
+	g := newPauseGate()
+	g.pause([]int32{0})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if g.acquire(ctx, 0) {
+		t.Fatal("acquire succeeded after session end")
+	}
+}
+
+func TestPauseGateDrain(t *testing.T) {
+	g := newPauseGate()
+	for _, partition := range []int32{0, 0, 1} {
+		if ok, _ := g.tryAcquire(partition); !ok {
+			t.Fatal("tryAcquire failed")
+		}
+	}
+	g.pause(nil)
+
+	// Партиция без сообщений в работе приостанавливается сразу
+	if err := g.drain(context.Background(), []int32{2}); err != nil {
+		t.Fatalf("drain idle partition: %v", err)
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- g.drain(context.Background(), nil) }()
+
+	g.release(0)
+	g.release(1)
+	select {
+	case err := <-drained:
+		t.Fatalf("drain returned with a message in flight: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	g.release(0)
+	if err := <-drained; err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+}
+
+func TestPauseGateDrainTimeout(t *testing.T) {
+	g := newPauseGate()
+	g.tryAcquire(0)
+	g.tryAcquire(0)
+	g.pause([]int32{0})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := g.drain(ctx, []int32{0})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("drain error = %v, want deadline exceeded", err)
+	}
+	if !g.paused(0) {
+		t.Error("partition resumed after drain timeout")
+	}
+}
+
+func TestPauseGateReset(t *testing.T) {
+	g := newPauseGate()
+	g.tryAcquire(0)
+	g.pause([]int32{0})
+
+	// Сообщения завершившейся сессии никогда не будут отпущены
+	g.reset()
+	if err := g.drain(context.Background(), nil); err != nil {
+		t.Fatalf("drain after reset: %v", err)
+	}
+	if !g.paused(0) {
+		t.Error("reset cleared the pause")
+	}
+
+	// Лишний release после reset не уводит счётчик в минус
+	g.release(0)
+	if ok, _ := g.tryAcquire(1); !ok {
+		t.Fatal("tryAcquire failed")
+	}
+	g.release(1)
+	if err := g.drain(context.Background(), nil); err != nil {
+		t.Fatalf("drain after extra release: %v", err)
+	}
+}
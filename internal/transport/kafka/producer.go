@@ -2,8 +2,11 @@ package kafka
 
 import (
 	"Order-tracker-service/config"
+	"Order-tracker-service/internal/domain"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -13,17 +16,67 @@ import (
 type Producer struct {
 	producer sarama.SyncProducer
 	config   *config.KafkaConfig
+	quiet    bool // не писать в лог каждое отправленное сообщение
+}
+
+// Message сообщение для отправки через Producer
+type Message struct {
+	Key     string // ключ партиционирования, пусто — сообщение без ключа
+	Value   []byte
+	Headers map[string]string
+
+	// Partition партиция для ручного партиционера (KAFKA_PARTITIONER=manual),
+	// с остальными партиционерами игнорируется
+	Partition int32
+}
+
+// producerMessage преобразует сообщение в сообщение Sarama
+func (m *Message) producerMessage(topic string) *sarama.ProducerMessage {
+	msg := &sarama.ProducerMessage{
+		Topic:     topic,
+		Value:     sarama.ByteEncoder(m.Value),
+		Partition: m.Partition,
+	}
+	if m.Key != "" {
+		msg.Key = sarama.StringEncoder(m.Key)
+	}
+	for key, value := range m.Headers {
+		msg.Headers = append(msg.Headers, stringHeader(key, value))
+	}
+	return msg
+}
+
+// NewOrderMessage сериализует заказ в JSON текущей версии схемы.
+// Ключом сообщения становится order_uid, чтобы все сообщения одного заказа
+// попадали в одну партицию и обрабатывались по порядку.
+func NewOrderMessage(order *domain.Order) (*Message, error) {
+	value, err := json.Marshal(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order: %w", err)
+	}
+
+	return &Message{
+		Key:   order.OrderUID,
+		Value: value,
+		Headers: map[string]string{
+			HeaderContentType:   ContentTypeJSON,
+			HeaderSchemaVersion: strconv.Itoa(CurrentSchemaVersion),
+		},
+	}, nil
 }
 
 // NewProducer создает новый экземпляр producer
 func NewProducer(cfg *config.KafkaConfig) (*Producer, error) {
-	// Настройка конфигурации Sarama
-	saramaConfig := sarama.NewConfig()
-	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
-	saramaConfig.Producer.Retry.Max = 3
-	saramaConfig.Producer.Return.Successes = true
-	saramaConfig.Producer.Timeout = 10 * time.Second
-	saramaConfig.Producer.Compression = sarama.CompressionSnappy
+	saramaConfig, err := newProducerConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	partitioner, err := newPartitioner(cfg.Partitioner)
+	if err != nil {
+		return nil, err
+	}
+	saramaConfig.Producer.Partitioner = partitioner
 
 	// Создание producer
 	producer, err := sarama.NewSyncProducer(cfg.Brokers, saramaConfig)
@@ -31,7 +84,7 @@ func NewProducer(cfg *config.KafkaConfig) (*Producer, error) {
 		return nil, fmt.Errorf("failed to create producer: %w", err)
 	}
 
-	log.Printf("Kafka producer created successfully, brokers: %v", cfg.Brokers)
+	log.Printf("Kafka producer created successfully, brokers: %v, partitioner: %s", cfg.Brokers, cfg.Partitioner)
 
 	return &Producer{
 		producer: producer,
@@ -39,23 +92,54 @@ func NewProducer(cfg *config.KafkaConfig) (*Producer, error) {
 	}, nil
 }
 
-// SendMessage отправляет сообщение в Kafka
-func (p *Producer) SendMessage(topic string, message []byte) error {
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(message),
-		Key:   sarama.StringEncoder("order"),
+// newProducerConfig возвращает настройки Sarama для синхронной отправки
+func newProducerConfig(cfg *config.KafkaConfig) (*sarama.Config, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig.Producer.Retry.Max = 3
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Timeout = 10 * time.Second
+
+	if err := saramaConfig.Producer.Compression.UnmarshalText([]byte(cfg.ProducerCompression)); err != nil {
+		return nil, fmt.Errorf("invalid producer compression: %w", err)
+	}
+
+	if err := applySecurity(saramaConfig, cfg); err != nil {
+		return nil, err
 	}
+	return saramaConfig, nil
+}
+
+// SendMessage отправляет сообщение в Kafka
+func (p *Producer) SendMessage(topic string, message *Message) error {
+	msg := message.producerMessage(topic)
 
 	partition, offset, err := p.producer.SendMessage(msg)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
 
-	log.Printf("Message sent to topic %s, partition %d, offset %d", topic, partition, offset)
+	if !p.quiet {
+		log.Printf("Message sent to topic %s, partition %d, offset %d", topic, partition, offset)
+	}
 	return nil
 }
 
+// SetQuiet отключает запись в лог каждого отправленного сообщения, например
+// под нагрузочным тестом, где она искажает замеры. Вызывается до отправки.
+func (p *Producer) SetQuiet(quiet bool) {
+	p.quiet = quiet
+}
+
+// SendOrder отправляет заказ в Kafka с ключом order_uid
+func (p *Producer) SendOrder(topic string, order *domain.Order) error {
+	message, err := NewOrderMessage(order)
+	if err != nil {
+		return err
+	}
+	return p.SendMessage(topic, message)
+}
+
 // Close закрывает producer
 func (p *Producer) Close() error {
 	if err := p.producer.Close(); err != nil {
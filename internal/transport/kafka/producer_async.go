@@ -0,0 +1,220 @@
+package kafka
+
+import (
+	"Order-tracker-service/config"
+	"Order-tracker-service/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// ErrProducerClosed возвращается при отправке через закрытый AsyncProducer
+var ErrProducerClosed = errors.New("producer is closed")
+
+// Delivery результат асинхронной отправки сообщения
+type Delivery struct {
+	Message   *Message
+	Topic     string
+	Partition int32
+	Offset    int64
+	Latency   time.Duration // от постановки в очередь до подтверждения брокером
+}
+
+// AsyncCallbacks вызываются из служебной горутины AsyncProducer'а по мере
+// подтверждения сообщений брокером; долгие обработчики тормозят отправку.
+// Любой из них может быть nil.
+type AsyncCallbacks struct {
+	OnSuccess func(Delivery)
+	OnError   func(Delivery, error)
+}
+
+// pendingMessage сообщение, ожидающее подтверждения
+type pendingMessage struct {
+	message  *Message
+	queuedAt time.Time
+}
+
+// AsyncProducer отправляет сообщения пачками, не дожидаясь подтверждения
+// каждого. Число неподтверждённых сообщений ограничено
+// KafkaConfig.ProducerMaxInFlight: при заполнении SendMessage блокируется.
+type AsyncProducer struct {
+	producer  sarama.AsyncProducer
+	callbacks AsyncCallbacks
+	slots     chan struct{} // семафор неподтверждённых сообщений
+	wg        sync.WaitGroup
+	mu        sync.RWMutex // защищает closed и запись в producer.Input()
+	closed    bool
+}
+
+// NewAsyncProducer создает асинхронный producer
+func NewAsyncProducer(cfg *config.KafkaConfig, callbacks AsyncCallbacks) (*AsyncProducer, error) {
+	if cfg.ProducerMaxInFlight <= 0 {
+		return nil, fmt.Errorf("producer max in-flight must be positive, got %d", cfg.ProducerMaxInFlight)
+	}
+
+	saramaConfig, err := newProducerConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	saramaConfig.Producer.Return.Errors = true
+	saramaConfig.Producer.Flush.Messages = cfg.ProducerBatchSize
+	saramaConfig.Producer.Flush.Frequency = cfg.ProducerLinger
+
+	partitioner, err := newPartitioner(cfg.Partitioner)
+	if err != nil {
+		return nil, err
+	}
+	saramaConfig.Producer.Partitioner = partitioner
+
+	producer, err := sarama.NewAsyncProducer(cfg.Brokers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create async producer: %w", err)
+	}
+
+	p := newAsyncProducer(producer, cfg.ProducerMaxInFlight, callbacks)
+
+	log.Printf("Kafka async producer created successfully, brokers: %v, batch size: %d, linger: %v, compression: %s",
+		cfg.Brokers, cfg.ProducerBatchSize, cfg.ProducerLinger, cfg.ProducerCompression)
+
+	return p, nil
+}
+
+// newAsyncProducer оборачивает producer Sarama и запускает обработку
+// подтверждений. producer должен возвращать и Successes, и Errors.
+func newAsyncProducer(producer sarama.AsyncProducer, maxInFlight int, callbacks AsyncCallbacks) *AsyncProducer {
+	p := &AsyncProducer{
+		producer:  producer,
+		callbacks: callbacks,
+		slots:     make(chan struct{}, maxInFlight),
+	}
+
+	p.wg.Add(2)
+	go p.handleSuccesses()
+	go p.handleErrors()
+	return p
+}
+
+// SendMessage ставит сообщение в очередь на отправку. Если неподтверждённых
+// сообщений уже ProducerMaxInFlight, ждёт освобождения места или отмены ctx.
+// Результат отправки передаётся в AsyncCallbacks.
+func (p *AsyncProducer) SendMessage(ctx context.Context, topic string, message *Message) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		<-p.slots
+		return ErrProducerClosed
+	}
+
+	msg := message.producerMessage(topic)
+	msg.Metadata = &pendingMessage{message: message, queuedAt: time.Now()}
+	p.producer.Input() <- msg
+	return nil
+}
+
+// SendOrder ставит заказ в очередь на отправку с ключом order_uid
+func (p *AsyncProducer) SendOrder(ctx context.Context, topic string, order *domain.Order) error {
+	message, err := NewOrderMessage(order)
+	if err != nil {
+		return err
+	}
+	return p.SendMessage(ctx, topic, message)
+}
+
+// Flush ждёт подтверждения всех сообщений, поставленных в очередь до вызова,
+// и завершения их обработчиков. На время ожидания новые отправки блокируются.
+func (p *AsyncProducer) Flush(ctx context.Context) error {
+	acquired := 0
+	defer func() {
+		for ; acquired > 0; acquired-- {
+			<-p.slots
+		}
+	}()
+
+	for acquired < cap(p.slots) {
+		select {
+		case p.slots <- struct{}{}:
+			acquired++
+		case <-ctx.Done():
+			return fmt.Errorf("failed to flush producer, %d message(s) pending: %w", cap(p.slots)-acquired, ctx.Err())
+		}
+	}
+	return nil
+}
+
+// InFlight возвращает число сообщений, ожидающих подтверждения
+func (p *AsyncProducer) InFlight() int {
+	return len(p.slots)
+}
+
+// handleSuccesses обрабатывает подтверждённые сообщения
+func (p *AsyncProducer) handleSuccesses() {
+	defer p.wg.Done()
+	for msg := range p.producer.Successes() {
+		if p.callbacks.OnSuccess != nil {
+			p.callbacks.OnSuccess(newDelivery(msg))
+		}
+		<-p.slots
+	}
+}
+
+// handleErrors обрабатывает сообщения, которые не удалось отправить
+// после всех повторов Sarama
+func (p *AsyncProducer) handleErrors() {
+	defer p.wg.Done()
+	for producerErr := range p.producer.Errors() {
+		delivery := newDelivery(producerErr.Msg)
+		if p.callbacks.OnError != nil {
+			p.callbacks.OnError(delivery, producerErr.Err)
+		} else {
+			log.Printf("Failed to send message to topic %s: %v", delivery.Topic, producerErr.Err)
+		}
+		<-p.slots
+	}
+}
+
+func newDelivery(msg *sarama.ProducerMessage) Delivery {
+	delivery := Delivery{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	}
+	if pending, ok := msg.Metadata.(*pendingMessage); ok {
+		delivery.Message = pending.message
+		delivery.Latency = time.Since(pending.queuedAt)
+	}
+	return delivery
+}
+
+// Close перестаёт принимать сообщения, дожидается отправки всех уже
+// поставленных в очередь и вызова их обработчиков, затем закрывает producer
+func (p *AsyncProducer) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	// AsyncClose отправляет накопленные пачки и закрывает каналы Successes
+	// и Errors, после чего служебные горутины завершаются. Close из Sarama
+	// не подходит: он сам вычитывает эти каналы, и обработчики не вызываются.
+	// Ошибки отправки при закрытии передаются в AsyncCallbacks.OnError.
+	p.producer.AsyncClose()
+	p.wg.Wait()
+
+	log.Println("Kafka async producer closed successfully")
+	return nil
+}
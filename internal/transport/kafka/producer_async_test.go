@@ -0,0 +1,214 @@
+package kafka
+
+import (
+	"Order-tracker-service/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// fakeAsyncProducer producer Sarama, который подтверждает сообщения только
+// по команде теста. AsyncClose подтверждает всё, что осталось в очереди,
+// и закрывает каналы результатов, как Sarama после отправки пачек.
+type fakeAsyncProducer struct {
+	sarama.AsyncProducer
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+}
+
+func newFakeAsyncProducer() *fakeAsyncProducer {
+	return &fakeAsyncProducer{
+		input:     make(chan *sarama.ProducerMessage, 100),
+		successes: make(chan *sarama.ProducerMessage),
+		errors:    make(chan *sarama.ProducerError),
+	}
+}
+
+func (p *fakeAsyncProducer) Input() chan<- *sarama.ProducerMessage     { return p.input }
+func (p *fakeAsyncProducer) Successes() <-chan *sarama.ProducerMessage { return p.successes }
+func (p *fakeAsyncProducer) Errors() <-chan *sarama.ProducerError      { return p.errors }
+
+func (p *fakeAsyncProducer) AsyncClose() {
+	go func() {
+		for {
+			select {
+			case msg := <-p.input:
+				p.successes <- msg
+			default:
+				close(p.successes)
+				close(p.errors)
+				return
+			}
+		}
+	}()
+}
+
+// ack подтверждает следующее сообщение очереди или, если err задан,
+// сообщает об ошибке его отправки
+func (p *fakeAsyncProducer) ack(err error) {
+	msg := <-p.input
+	if err != nil {
+		p.errors <- &sarama.ProducerError{Msg: msg, Err: err}
+		return
+	}
+	p.successes <- msg
+}
+
+// deliveries считает вызовы AsyncCallbacks
+type deliveries struct {
+	mu        sync.Mutex
+	successes []string
+	errors    []string
+}
+
+func (d *deliveries) callbacks() AsyncCallbacks {
+	return AsyncCallbacks{
+		OnSuccess: func(delivery Delivery) {
+			// Медленный обработчик: Close и Flush должны его дождаться
+			time.Sleep(time.Millisecond)
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.successes = append(d.successes, delivery.Message.Key)
+		},
+		OnError: func(delivery Delivery, _ error) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.errors = append(d.errors, delivery.Message.Key)
+		},
+	}
+}
+
+func (d *deliveries) count() (successes, errors int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.successes), len(d.errors)
+}
+
+func sendKeys(t *testing.T, p *AsyncProducer, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if err := p.SendMessage(context.Background(), testTopic, &Message{Key: key}); err != nil {
+			t.Fatalf("SendMessage %s: %v", key, err)
+		}
+	}
+}
+
+func TestAsyncProducerBackpressure(t *testing.T) {
+	fake := newFakeAsyncProducer()
+	p := newAsyncProducer(fake, 2, AsyncCallbacks{})
+	defer func() {
+		p.Close()
+	}()
+
+	sendKeys(t, p, "a", "b")
+	if got := p.InFlight(); got != 2 {
+		t.Fatalf("InFlight = %d, want 2", got)
+	}
+
+	// При заполненном лимите отправка ждёт подтверждения или отмены ctx
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	order := domain.GetTestOrder()
+	if err := p.SendOrder(ctx, testTopic, order); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SendOrder at the in-flight limit = %v, want deadline exceeded", err)
+	}
+
+	sent := make(chan error, 1)
+	go func() { sent <- p.SendOrder(context.Background(), testTopic, order) }()
+	select {
+	case err := <-sent:
+		t.Fatalf("SendOrder returned at the in-flight limit: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	fake.ack(errors.New("broker unavailable"))
+	if err := <-sent; err != nil {
+		t.Fatalf("SendOrder after a slot was freed: %v", err)
+	}
+	if got := p.InFlight(); got != 2 {
+		t.Errorf("InFlight = %d, want 2", got)
+	}
+}
+
+func TestAsyncProducerFlush(t *testing.T) {
+	fake := newFakeAsyncProducer()
+	var got deliveries
+	p := newAsyncProducer(fake, 5, got.callbacks())
+	defer func() {
+		p.Close()
+	}()
+
+	sendKeys(t, p, "a", "b", "c")
+
+	// Без подтверждений Flush упирается в ctx и сообщает о неподтверждённых
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := p.Flush(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "3 message(s) pending") {
+		t.Fatalf("Flush without acks = %v, want deadline exceeded with 3 pending", err)
+	}
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- p.Flush(context.Background()) }()
+
+	fake.ack(nil)
+	fake.ack(errors.New("message too large"))
+	select {
+	case err := <-flushed:
+		t.Fatalf("Flush returned with a message unacknowledged: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	fake.ack(nil)
+	if err := <-flushed; err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if successes, failures := got.count(); successes != 2 || failures != 1 {
+		t.Errorf("callbacks before Flush returned: %d successes, %d errors; want 2 and 1", successes, failures)
+	}
+	if got := p.InFlight(); got != 0 {
+		t.Errorf("InFlight after Flush = %d, want 0", got)
+	}
+
+	// После Flush отправка снова доступна
+	sendKeys(t, p, "d")
+}
+
+func TestAsyncProducerCloseDrains(t *testing.T) {
+	const messages = 20
+	fake := newFakeAsyncProducer()
+	var got deliveries
+	p := newAsyncProducer(fake, messages, got.callbacks())
+
+	keys := make([]string, messages)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("order-%d", i)
+	}
+	sendKeys(t, p, keys...)
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if successes, failures := got.count(); successes != messages || failures != 0 {
+		t.Fatalf("callbacks before Close returned: %d successes, %d errors; want %d and 0", successes, failures, messages)
+	}
+	for i, key := range got.successes {
+		if key != keys[i] {
+			t.Fatalf("delivery %d = %s, want %s", i, key, keys[i])
+		}
+	}
+
+	if err := p.SendMessage(context.Background(), testTopic, &Message{Key: "late"}); !errors.Is(err, ErrProducerClosed) {
+		t.Errorf("SendMessage after Close = %v, want ErrProducerClosed", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package kafka
+
+import (
+	"Order-tracker-service/config"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// ReplaySpec описывает, с какого места переиграть сообщения топика.
+// Задаётся либо Offset, либо Since.
+type ReplaySpec struct {
+	Partitions []int32   // пусто — все партиции топика
+	Offset     int64     // offset, с которого читать заново; -1 — не задан
+	Since      time.Time // первый offset с временем сообщения не раньше Since
+}
+
+// PartitionReplay план переигрывания одной партиции
+type PartitionReplay struct {
+	Partition int32 `json:"partition"`
+	Committed int64 `json:"committed"` // текущий зафиксированный offset группы, -1 — нет
+	From      int64 `json:"from"`      // offset, на который будет сброшена группа
+	HighWater int64 `json:"high_water"`
+	Messages  int64 `json:"messages"` // сколько сообщений будет обработано повторно (оценка)
+}
+
+// Replayer сбрасывает offset'ы группы консьюмеров, чтобы при следующем
+// запуске консьюмер заново прочитал сообщения через обычный путь HandleOrder
+type Replayer struct {
+	client sarama.Client
+	admin  sarama.ClusterAdmin
+	group  string
+	topic  string
+}
+
+// NewReplayer подключается к кластеру с настройками консьюмера
+func NewReplayer(cfg *config.KafkaConfig) (*Replayer, error) {
+	saramaConfig := sarama.NewConfig()
+	if err := applySecurity(saramaConfig, cfg); err != nil {
+		return nil, err
+	}
+
+	client, err := sarama.NewClient(cfg.Brokers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create kafka admin: %w", err)
+	}
+
+	return &Replayer{
+		client: client,
+		admin:  admin,
+		group:  cfg.GroupID,
+		topic:  cfg.Topic,
+	}, nil
+}
+
+// Plan рассчитывает новые offset'ы по партициям, ничего не меняя
+func (r *Replayer) Plan(spec ReplaySpec) ([]PartitionReplay, error) {
+	if spec.Offset < 0 && spec.Since.IsZero() {
+		return nil, errors.New("replay: either offset or timestamp is required")
+	}
+	if spec.Offset >= 0 && !spec.Since.IsZero() {
+		return nil, errors.New("replay: offset and timestamp are mutually exclusive")
+	}
+
+	all, err := r.client.Partitions(r.topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", r.topic, err)
+	}
+	partitions := spec.Partitions
+	if len(partitions) == 0 {
+		partitions = all
+	}
+	for _, p := range partitions {
+		if !slices.Contains(all, p) {
+			return nil, fmt.Errorf("replay: topic %s has no partition %d", r.topic, p)
+		}
+	}
+
+	committed, err := r.admin.ListConsumerGroupOffsets(r.group, map[string][]int32{r.topic: partitions})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch offsets of group %s: %w", r.group, err)
+	}
+
+	plan := make([]PartitionReplay, 0, len(partitions))
+	for _, p := range partitions {
+		oldest, err := r.client.GetOffset(r.topic, p, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		newest, err := r.client.GetOffset(r.topic, p, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+
+		from := spec.Offset
+		if !spec.Since.IsZero() {
+			if from, err = r.client.GetOffset(r.topic, p, spec.Since.UnixMilli()); err != nil {
+				return nil, err
+			}
+			// Сообщений не раньше Since нет — переигрывать нечего
+			if from < 0 {
+				from = newest
+			}
+		}
+		from = min(max(from, oldest), newest)
+
+		entry := PartitionReplay{
+			Partition: p,
+			Committed: -1,
+			From:      from,
+			HighWater: newest,
+			Messages:  newest - from,
+		}
+		if block := committed.GetBlock(r.topic, p); block != nil {
+			entry.Committed = block.Offset
+		}
+		plan = append(plan, entry)
+	}
+
+	return plan, nil
+}
+
+// Apply сбрасывает offset'ы группы по плану. Группа должна быть остановлена:
+// Kafka не даёт менять offset'ы группы с активными участниками.
+func (r *Replayer) Apply(plan []PartitionReplay) error {
+	groups, err := r.admin.DescribeConsumerGroups([]string{r.group})
+	if err != nil {
+		return fmt.Errorf("failed to describe group %s: %w", r.group, err)
+	}
+	if len(groups) > 0 && len(groups[0].Members) > 0 {
+		return fmt.Errorf("replay: group %s has %d active member(s), stop the consumers first", r.group, len(groups[0].Members))
+	}
+
+	om, err := sarama.NewOffsetManagerFromClient(r.group, r.client)
+	if err != nil {
+		return fmt.Errorf("failed to create offset manager: %w", err)
+	}
+	defer om.Close()
+
+	for _, entry := range plan {
+		pom, err := om.ManagePartition(r.topic, entry.Partition)
+		if err != nil {
+			return fmt.Errorf("failed to manage partition %d: %w", entry.Partition, err)
+		}
+		// MarkOffset двигает offset только вперёд, ResetOffset — только назад
+		pom.MarkOffset(entry.From, "replay")
+		pom.ResetOffset(entry.From, "replay")
+		log.Printf("Group %s: partition %d offset %d -> %d (%d messages to replay)",
+			r.group, entry.Partition, entry.Committed, entry.From, entry.Messages)
+	}
+
+	om.Commit()
+
+	// Ошибки коммита Sarama не возвращает синхронно, поэтому сверяем результат
+	partitions := make([]int32, len(plan))
+	for i, entry := range plan {
+		partitions[i] = entry.Partition
+	}
+	committed, err := r.admin.ListConsumerGroupOffsets(r.group, map[string][]int32{r.topic: partitions})
+	if err != nil {
+		return fmt.Errorf("failed to verify offsets of group %s: %w", r.group, err)
+	}
+	for _, entry := range plan {
+		block := committed.GetBlock(r.topic, entry.Partition)
+		if block == nil || block.Offset != entry.From {
+			return fmt.Errorf("replay: offset of partition %d was not committed", entry.Partition)
+		}
+	}
+	return nil
+}
+
+// Close закрывает подключение к кластеру
+func (r *Replayer) Close() error {
+	if err := r.admin.Close(); err != nil {
+		return fmt.Errorf("failed to close kafka admin: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// permanentError ошибка обработки, повтор которой не поможет:
+// битый JSON, невалидный заказ и т.п.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string   { return e.err.Error() }
+func (e *permanentError) Unwrap() error   { return e.err }
+func (e *permanentError) Permanent() bool { return true }
+
+// permanent помечает ошибку как постоянную
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// isPermanent проверяет, помечена ли ошибка как постоянная. Обработчик
+// сообщений может помечать свои ошибки любым типом с методом Permanent() bool.
+func isPermanent(err error) bool {
+	var p interface{ Permanent() bool }
+	return errors.As(err, &p) && p.Permanent()
+}
+
+// retryPolicy параметры повторов для временных ошибок
+type retryPolicy struct {
+	maxRetries int           // сколько раз повторять после первой попытки
+	baseDelay  time.Duration // задержка перед первым повтором
+	maxDelay   time.Duration // верхняя граница задержки
+}
+
+// backoff возвращает задержку перед повтором с номером retry (начиная с 1):
+// экспоненциальный рост с равномерным джиттером в диапазоне [d/2, d]
+func (p retryPolicy) backoff(retry int) time.Duration {
+	d := p.baseDelay
+	for i := 1; i < retry && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	if d > p.maxDelay {
+		d = p.maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// do выполняет fn, повторяя временные ошибки с задержкой. Возвращает число
+// сделанных попыток и последнюю ошибку. Отмена ctx (например, при ребалансировке)
+// прерывает ожидание и возвращает ctx.Err().
+func (p retryPolicy) do(ctx context.Context, fn func() error) (int, error) {
+	attempt := 1
+	for ; ; attempt++ {
+		err := fn()
+		if err == nil || isPermanent(err) || attempt > p.maxRetries {
+			return attempt, err
+		}
+
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package kafka
+
+import (
+	"Order-tracker-service/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// testCertFiles записывает во временный каталог самоподписанный сертификат
+// и его ключ в PEM и возвращает пути к ним
+func testCertFiles(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "order-tracker-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplySecurityErrors(t *testing.T) {
+	certFile, keyFile := testCertFiles(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		tls     config.KafkaTLSConfig
+		sasl    config.KafkaSASLConfig
+		wantErr string
+	}{
+		{
+			name:    "unknown mechanism",
+			sasl:    config.KafkaSASLConfig{Mechanism: "GSSAPI", Username: "user"},
+			wantErr: `unknown SASL mechanism "GSSAPI"`,
+		},
+		{
+			name:    "sasl without username",
+			sasl:    config.KafkaSASLConfig{Mechanism: "PLAIN", Password: "secret"},
+			wantErr: "SASL username is required",
+		},
+		{
+			name:    "certificate without key",
+			tls:     config.KafkaTLSConfig{Enabled: true, CertFile: certFile},
+			wantErr: "both client certificate and key files are required",
+		},
+		{
+			name:    "key without certificate",
+			tls:     config.KafkaTLSConfig{Enabled: true, KeyFile: keyFile},
+			wantErr: "both client certificate and key files are required",
+		},
+		{
+			name:    "certificate and key swapped",
+			tls:     config.KafkaTLSConfig{Enabled: true, CertFile: keyFile, KeyFile: certFile},
+			wantErr: "failed to load client certificate",
+		},
+		{
+			name:    "unreadable CA file",
+			tls:     config.KafkaTLSConfig{Enabled: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			wantErr: "failed to read CA file",
+		},
+		{
+			name:    "CA file without certificates",
+			tls:     config.KafkaTLSConfig{Enabled: true, CAFile: notPEM},
+			wantErr: "no certificates found in CA file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := applySecurity(sarama.NewConfig(), &config.KafkaConfig{TLS: tt.tls, SASL: tt.sasl})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("applySecurity error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplySecurity(t *testing.T) {
+	certFile, keyFile := testCertFiles(t)
+
+	tests := []struct {
+		name          string
+		tls           config.KafkaTLSConfig
+		sasl          config.KafkaSASLConfig
+		wantMechanism sarama.SASLMechanism // пусто — SASL выключен
+		wantSCRAM     bool
+		wantCerts     int
+		wantRootCAs   bool
+	}{
+		{
+			name: "plaintext",
+		},
+		{
+			name: "tls with system CAs",
+			tls:  config.KafkaTLSConfig{Enabled: true},
+		},
+		{
+			name:        "mutual tls",
+			tls:         config.KafkaTLSConfig{Enabled: true, CAFile: certFile, CertFile: certFile, KeyFile: keyFile},
+			wantCerts:   1,
+			wantRootCAs: true,
+		},
+		{
+			name:          "plain",
+			sasl:          config.KafkaSASLConfig{Mechanism: "PLAIN", Username: "user", Password: "secret"},
+			wantMechanism: sarama.SASLTypePlaintext,
+		},
+		{
+			name:          "scram-sha-256 in lower case",
+			tls:           config.KafkaTLSConfig{Enabled: true},
+			sasl:          config.KafkaSASLConfig{Mechanism: "scram-sha-256", Username: "user", Password: "secret"},
+			wantMechanism: sarama.SASLTypeSCRAMSHA256,
+			wantSCRAM:     true,
+		},
+		{
+			name:          "scram-sha-512",
+			tls:           config.KafkaTLSConfig{Enabled: true},
+			sasl:          config.KafkaSASLConfig{Mechanism: "SCRAM-SHA-512", Username: "user", Password: "secret"},
+			wantMechanism: sarama.SASLTypeSCRAMSHA512,
+			wantSCRAM:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saramaConfig := sarama.NewConfig()
+			if err := applySecurity(saramaConfig, &config.KafkaConfig{TLS: tt.tls, SASL: tt.sasl}); err != nil {
+				t.Fatalf("applySecurity: %v", err)
+			}
+
+			netCfg := saramaConfig.Net
+			if netCfg.TLS.Enable != tt.tls.Enabled {
+				t.Errorf("TLS.Enable = %v, want %v", netCfg.TLS.Enable, tt.tls.Enabled)
+			}
+			if tt.tls.Enabled {
+				if got := len(netCfg.TLS.Config.Certificates); got != tt.wantCerts {
+					t.Errorf("client certificates = %d, want %d", got, tt.wantCerts)
+				}
+				if got := netCfg.TLS.Config.RootCAs != nil; got != tt.wantRootCAs {
+					t.Errorf("custom RootCAs = %v, want %v", got, tt.wantRootCAs)
+				}
+			}
+
+			if netCfg.SASL.Enable != (tt.wantMechanism != "") {
+				t.Fatalf("SASL.Enable = %v, want %v", netCfg.SASL.Enable, tt.wantMechanism != "")
+			}
+			if tt.wantMechanism == "" {
+				return
+			}
+			if netCfg.SASL.Mechanism != tt.wantMechanism {
+				t.Errorf("SASL.Mechanism = %s, want %s", netCfg.SASL.Mechanism, tt.wantMechanism)
+			}
+			if netCfg.SASL.User != tt.sasl.Username || netCfg.SASL.Password != tt.sasl.Password {
+				t.Errorf("SASL credentials = %q/%q, want %q/%q", netCfg.SASL.User, netCfg.SASL.Password, tt.sasl.Username, tt.sasl.Password)
+			}
+			if (netCfg.SASL.SCRAMClientGeneratorFunc != nil) != tt.wantSCRAM {
+				t.Fatalf("SCRAMClientGeneratorFunc set = %v, want %v", netCfg.SASL.SCRAMClientGeneratorFunc != nil, tt.wantSCRAM)
+			}
+			if tt.wantSCRAM {
+				client := netCfg.SASL.SCRAMClientGeneratorFunc()
+				if err := client.Begin(netCfg.SASL.User, netCfg.SASL.Password, ""); err != nil {
+					t.Fatalf("SCRAM Begin: %v", err)
+				}
+				first, err := client.Step("")
+				if err != nil || !strings.HasPrefix(first, "n,,n=user,r=") {
+					t.Errorf("SCRAM client-first message = %q, %v", first, err)
+				}
+			}
+		})
+	}
+}
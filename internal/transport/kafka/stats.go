@@ -0,0 +1,217 @@
+package kafka
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+const (
+	// errorWindow за какой период считаются недавние ошибки
+	errorWindow = 5 * time.Minute
+	// maxRecentErrors ограничивает память на хранение времени ошибок
+	maxRecentErrors = 1000
+	// highWaterMarkRefresh как часто high-water mark назначенных партиций
+	// запрашивается у брокера, независимо от получения сообщений
+	highWaterMarkRefresh = 5 * time.Second
+)
+
+// PartitionStatus состояние обработки одной партиции
+type PartitionStatus struct {
+	Topic         string     `json:"topic"`
+	Partition     int32      `json:"partition"`
+	Assigned      bool       `json:"assigned"`
+	Paused        bool       `json:"paused"`
+	LastOffset    int64      `json:"last_offset"`     // последний обработанный offset, -1 — ещё не было
+	HighWaterMark int64      `json:"high_water_mark"` // offset следующего сообщения, которое будет записано в партицию
+	Lag           int64      `json:"lag"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	Processed     int64      `json:"processed"`
+	Errors        int64      `json:"errors"`
+	RecentErrors  int        `json:"recent_errors"` // ошибок за последние errorWindow
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Status состояние консьюмера
+type Status struct {
+	Running    bool              `json:"running"`
+	GroupID    string            `json:"group_id"`
+	Topic      string            `json:"topic"`
+	Lag        int64             `json:"lag"`
+	Stalled    bool              `json:"stalled"`
+	Partitions []PartitionStatus `json:"partitions"`
+}
+
+// partitionStats счётчики партиции; доступ под consumerStats.mu
+type partitionStats struct {
+	status       PartitionStatus
+	recentErrors []time.Time
+}
+
+// consumerStats собирает статистику обработки по партициям
+type consumerStats struct {
+	mu         sync.Mutex
+	partitions map[int32]*partitionStats
+}
+
+func newConsumerStats() *consumerStats {
+	return &consumerStats{partitions: make(map[int32]*partitionStats)}
+}
+
+func (s *consumerStats) partition(topic string, partition int32) *partitionStats {
+	p, ok := s.partitions[partition]
+	if !ok {
+		p = &partitionStats{status: PartitionStatus{Topic: topic, Partition: partition, LastOffset: -1}}
+		s.partitions[partition] = p
+	}
+	return p
+}
+
+// assign отмечает партиции, назначенные консьюмеру в новой сессии
+func (s *consumerStats) assign(claims map[string][]int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.partitions {
+		p.status.Assigned = false
+	}
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			s.partition(topic, partition).status.Assigned = true
+		}
+	}
+}
+
+// start отмечает начало обработки партиции с offset'а initial: сообщения
+// до него считаются обработанными, иначе до первого обработанного сообщения
+// отставание равнялось бы всей длине партиции
+func (s *consumerStats) start(topic string, partition int32, initial, highWaterMark int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.partition(topic, partition)
+	p.status.LastOffset = initial - 1
+	p.status.HighWaterMark = max(p.status.HighWaterMark, highWaterMark)
+}
+
+// received обновляет high-water mark партиции при получении сообщения
+func (s *consumerStats) received(claim sarama.ConsumerGroupClaim) {
+	s.setHighWaterMark(claim.Topic(), claim.Partition(), claim.HighWaterMarkOffset())
+}
+
+// setHighWaterMark обновляет high-water mark партиции
+func (s *consumerStats) setHighWaterMark(topic string, partition int32, highWaterMark int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partition(topic, partition).status.HighWaterMark = highWaterMark
+}
+
+// processed отмечает успешно обработанное сообщение
+func (s *consumerStats) processed(message *sarama.ConsumerMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	p := s.partition(message.Topic, message.Partition)
+	p.status.Processed++
+	p.status.LastSuccessAt = &now
+}
+
+// advance сдвигает последний обработанный offset партиции. Вызывается для
+// последнего сообщения непрерывно обработанного префикса, а не для каждого
+// завершённого: при нескольких воркерах более поздние сообщения завершаются
+// раньше, но offset за них не фиксируется.
+func (s *consumerStats) advance(message *sarama.ConsumerMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.partition(message.Topic, message.Partition)
+	if message.Offset > p.status.LastOffset {
+		p.status.LastOffset = message.Offset
+	}
+}
+
+// failed отмечает неудачную попытку обработки сообщения
+func (s *consumerStats) failed(message *sarama.ConsumerMessage, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.partition(message.Topic, message.Partition)
+	now := time.Now()
+	p.status.Errors++
+	p.status.LastError = err.Error()
+	p.status.LastErrorAt = &now
+	p.recentErrors = append(p.recentErrors, now)
+	if len(p.recentErrors) > maxRecentErrors {
+		p.recentErrors = p.recentErrors[len(p.recentErrors)-maxRecentErrors:]
+	}
+}
+
+// snapshot возвращает копию статистики по партициям, отсортированную по номеру
+func (s *consumerStats) snapshot() []PartitionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().Add(-errorWindow)
+	result := make([]PartitionStatus, 0, len(s.partitions))
+	for _, p := range s.partitions {
+		// Отбрасываем ошибки старше окна
+		i := sort.Search(len(p.recentErrors), func(i int) bool { return p.recentErrors[i].After(cutoff) })
+		p.recentErrors = p.recentErrors[i:]
+
+		status := p.status
+		status.RecentErrors = len(p.recentErrors)
+		status.Lag = max(status.HighWaterMark-status.LastOffset-1, 0)
+		result = append(result, status)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Partition < result[j].Partition })
+	return result
+}
+
+// startClaim отмечает в статистике начало обработки партиции. Если у группы
+// нет сохранённого offset'а, claim начинается с OffsetOldest или
+// OffsetNewest, и реальный offset запрашивается у брокера.
+func (c *Consumer) startClaim(claim sarama.ConsumerGroupClaim) {
+	initial := claim.InitialOffset()
+	if initial < 0 {
+		resolved, err := c.client.GetOffset(claim.Topic(), claim.Partition(), initial)
+		if err != nil {
+			log.Printf("Failed to resolve initial offset of partition %d: %v", claim.Partition(), err)
+			return
+		}
+		initial = resolved
+	}
+	c.stats.start(claim.Topic(), claim.Partition(), initial, claim.HighWaterMarkOffset())
+}
+
+// refreshHighWaterMarks периодически запрашивает у брокера high-water mark
+// назначенных партиций, чтобы отставание росло и тогда, когда консьюмер
+// не получает сообщений: простаивает или завис на обработке
+func (c *Consumer) refreshHighWaterMarks() {
+	ticker := time.NewTicker(highWaterMarkRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, p := range c.stats.snapshot() {
+				if !p.Assigned {
+					continue
+				}
+				highWaterMark, err := c.client.GetOffset(p.Topic, p.Partition, sarama.OffsetNewest)
+				if err != nil {
+					log.Printf("Failed to refresh high-water mark of partition %d: %v", p.Partition, err)
+					continue
+				}
+				c.stats.setHighWaterMark(p.Topic, p.Partition, highWaterMark)
+			}
+		}
+	}
+}
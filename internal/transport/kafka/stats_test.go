@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"Order-tracker-service/internal/domain"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConsumerStatsLag(t *testing.T) {
+	s := newConsumerStats()
+	s.assign(map[string][]int32{testTopic: {0}})
+
+	lag := func() int64 {
+		t.Helper()
+		return s.snapshot()[0].Lag
+	}
+
+	// Партиция назначена после рестарта: до первого обработанного сообщения
+	// отставание считается от зафиксированного offset'а, а не от начала партиции
+	s.start(testTopic, 0, 1_000_000, 1_000_003)
+	if got := lag(); got != 3 {
+		t.Fatalf("lag after claim start = %d, want 3", got)
+	}
+
+	// Новые сообщения видны без их получения консьюмером
+	s.setHighWaterMark(testTopic, 0, 1_000_010)
+	if got := lag(); got != 10 {
+		t.Fatalf("lag after high-water mark refresh = %d, want 10", got)
+	}
+
+	// Claim без сведений о high-water mark его не сбрасывает
+	s.start(testTopic, 0, 1_000_000, 0)
+	if got := lag(); got != 10 {
+		t.Fatalf("lag after claim restart = %d, want 10", got)
+	}
+}
+
+// TestConsumeClaimLagWhileBlocked проверяет отставание партиции, пока первое
+// после рестарта сообщение застряло в обработке
+func TestConsumeClaimLagWhileBlocked(t *testing.T) {
+	log := orderLog(t, 0, "order-0", "order-1", "order-2", "order-3")
+	handler := &blockingHandler{
+		started: make(chan string, len(log)),
+		release: make(chan struct{}),
+	}
+	c := newTestConsumer(t, AtLeastOnce, 1, handler)
+	c.stats.assign(map[string][]int32{testTopic: {0}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session := newFakeSession(ctx, map[int32]int64{0: 2})
+	claim := newFakeClaim(0, 2, log, ctx.Done())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.ConsumeClaim(session, claim)
+	}()
+	<-handler.started
+
+	status := c.Status().Partitions[0]
+	if status.LastOffset != 1 || status.Lag != 2 {
+		t.Errorf("while blocked: last offset %d, lag %d; want 1 and 2", status.LastOffset, status.Lag)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ConsumeClaim did not return")
+	}
+}
+
+// holdingHandler держит обработку одного заказа, пока не закрыт release;
+// остальные заказы сохраняются сразу
+type holdingHandler struct {
+	savingHandler
+	held    string
+	release chan struct{}
+}
+
+func (h *holdingHandler) HandleOrder(ctx context.Context, order *domain.Order) error {
+	if order.OrderUID == h.held {
+		select {
+		case <-h.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return h.savingHandler.HandleOrder(ctx, order)
+}
+
+// TestConsumeParallelLastOffset проверяет, что при нескольких воркерах
+// последний обработанный offset не обгоняет фиксируемый
+func TestConsumeParallelLastOffset(t *testing.T) {
+	const workers = 4
+	log := orderLog(t, 0, "order-0", "order-1")
+	if workerFor(log[0], workers) == workerFor(log[1], workers) {
+		t.Fatal("test orders must go to different workers")
+	}
+	handler := &holdingHandler{held: "order-0", release: make(chan struct{})}
+	c := newTestConsumer(t, AtLeastOnce, workers, handler)
+	c.stats.assign(map[string][]int32{testTopic: {0}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := newFakeSession(ctx, map[int32]int64{})
+	claim := newFakeClaim(0, 0, log, ctx.Done())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.ConsumeClaim(session, claim)
+	}()
+
+	waitFor(t, "order-1 to be processed", func() bool {
+		return c.Status().Partitions[0].Processed == 1
+	})
+	if status := c.Status().Partitions[0]; status.LastOffset != -1 || status.Lag != 2 {
+		t.Errorf("with order-0 in flight: last offset %d, lag %d; want -1 and 2", status.LastOffset, status.Lag)
+	}
+
+	close(handler.release)
+	waitFor(t, "offset 2 to be marked", func() bool { return session.offset(0) == 2 })
+	if status := c.Status().Partitions[0]; status.LastOffset != 1 || status.Lag != 0 {
+		t.Errorf("after order-0: last offset %d, lag %d; want 1 and 0", status.LastOffset, status.Lag)
+	}
+
+	cancel()
+	<-done
+}
+
+// waitFor ждёт выполнения условия не дольше 5 секунд
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
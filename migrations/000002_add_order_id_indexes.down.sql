@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_orders_date_created_id;
+DROP INDEX IF EXISTS idx_items_order_id;
+DROP INDEX IF EXISTS idx_payment_order_id;
+DROP INDEX IF EXISTS idx_delivery_order_id;
@@ -0,0 +1,7 @@
+-- Индексы по внешним ключам для пакетной загрузки delivery, payment и items
+CREATE INDEX IF NOT EXISTS idx_delivery_order_id ON delivery (order_id);
+CREATE INDEX IF NOT EXISTS idx_payment_order_id ON payment (order_id);
+CREATE INDEX IF NOT EXISTS idx_items_order_id ON items (order_id);
+
+-- Индекс для выборки самых свежих заказов при прогреве кэша
+CREATE INDEX IF NOT EXISTS idx_orders_date_created_id ON orders (date_created DESC, id DESC);
@@ -0,0 +1,11 @@
+-- Индексы для поиска заказов по фильтрам
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders (track_number);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_service ON orders (delivery_service);
+
+CREATE INDEX IF NOT EXISTS idx_delivery_phone ON delivery (phone);
+CREATE INDEX IF NOT EXISTS idx_delivery_email ON delivery (email);
+
+CREATE INDEX IF NOT EXISTS idx_items_rid ON items (rid);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items (nm_id);
+CREATE INDEX IF NOT EXISTS idx_items_brand ON items (brand);
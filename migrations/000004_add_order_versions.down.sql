@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS order_versions;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS content_hash;
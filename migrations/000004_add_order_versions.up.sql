@@ -0,0 +1,17 @@
+-- Хэш содержимого для распознавания повторных доставок и номер версии заказа
+ALTER TABLE orders
+    ADD COLUMN content_hash TEXT,
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN updated_at TIMESTAMPTZ;
+
+-- Прежние версии заказов при политике конфликтов "version"
+CREATE TABLE order_versions (
+                                id SERIAL PRIMARY KEY,
+                                order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+                                order_uid TEXT NOT NULL,
+                                version INTEGER NOT NULL,
+                                content_hash TEXT NOT NULL,
+                                payload JSONB NOT NULL,
+                                archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                UNIQUE (order_uid, version)
+);
@@ -0,0 +1,16 @@
+-- События о сохранённых заказах, которые пишутся в одной транзакции с заказом
+-- и публикуются в Kafka отдельным воркером
+CREATE TABLE outbox (
+                        id BIGSERIAL PRIMARY KEY,
+                        event_type TEXT NOT NULL,
+                        order_uid TEXT NOT NULL,
+                        payload JSONB NOT NULL,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                        attempts INTEGER NOT NULL DEFAULT 0,
+                        last_error TEXT,
+                        published_at TIMESTAMPTZ
+);
+
+-- Очередь неопубликованных событий и очистка опубликованных
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL;